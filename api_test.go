@@ -1,17 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/storage"
 )
 
 func TestAPI(t *testing.T) {
-	inTest = true
-	r := NewEngine()
+	if os.Getenv("DATABASE_URL") == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	r := NewEngine(storage.NewMemory())
 	var ret struct {
 		Id int `json:"id"`
 	}
@@ -42,7 +49,60 @@ func TestAPI(t *testing.T) {
 		}
 	}
 	{
-		req, err := http.NewRequest("POST", "/code", strings.NewReader(`{"source":"#include <stdio.h>\r\nint main()\r\n{\r\n       \tint n;\r\n       \tint x,y;\r\n       \tscanf(\"%d\",&n);\r\n       \tfor(int i = 0; i < n; i++)\r\n       \t{\r\n       \t\tscanf(\"%d %d\",&x,&y);\r\n       \t\tprintf(\"%d\\n\",x+y);\r\n       \t}\r\n}","language":"c","problemId":6}`))
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		for name, test := range map[string]string{"input": "2\n1 2\n3 4\n", "output": "3\n7\n"} {
+			part, err := w.CreateFormFile(name, name+".txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write([]byte(test))
+		}
+		w.Close()
+		req, err := http.NewRequest("PUT", fmt.Sprintf("/problem/%d/tests", ret.Id), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, req)
+		if res.Code != 200 {
+			t.Fatalf("PUT /problem/%d/tests failed, response: %s\n", ret.Id, res.Body.Bytes())
+		}
+	}
+	{
+		req, err := http.NewRequest("POST", fmt.Sprintf("/problem/%d/uploads", ret.Id), strings.NewReader(`{"nth": 1, "name": "output", "size": 5}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, req)
+		if res.Code != 200 {
+			t.Fatalf("POST /problem/%d/uploads failed, response: %s\n", ret.Id, res.Body.Bytes())
+		}
+		var upload struct {
+			Upload struct {
+				Id string `json:"id"`
+			} `json:"upload"`
+		}
+		if err := json.Unmarshal(res.Body.Bytes(), &upload); err != nil {
+			t.Fatal(err)
+		}
+		for _, chunk := range []struct{ rng, data string }{{"bytes 0-1/5", "3\n"}, {"bytes 2-4/5", "10\n"}} {
+			req, err := http.NewRequest("PUT", "/upload/"+upload.Upload.Id, strings.NewReader(chunk.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Range", chunk.rng)
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, req)
+			if res.Code != 200 {
+				t.Fatalf("PUT /upload/%s failed, response: %s\n", upload.Upload.Id, res.Body.Bytes())
+			}
+		}
+	}
+	{
+		req, err := http.NewRequest("POST", "/code", strings.NewReader(`{"source":"#include <stdio.h>\r\nint main()\r\n{\r\n       \tint n;\r\n       \tint x,y;\r\n       \tscanf(\"%d\",&n);\r\n       \tfor(int i = 0; i < n; i++)\r\n       \t{\r\n       \t\tscanf(\"%d %d\",&x,&y);\r\n       \t\tprintf(\"%d\\n\",x+y);\r\n       \t}\r\n}","language":"c","problemId":`+fmt.Sprint(ret.Id)+`}`))
 		if err != nil {
 			t.Fatal(err)
 		}
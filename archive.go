@@ -0,0 +1,352 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// testPair is a test case found in an archive.
+type testPair struct {
+	Name   string `json:"name"`   // common name of input and output like "01"
+	Input  string `json:"input"`  // path of input in the archive
+	Output string `json:"output"` // path of output in the archive
+}
+
+// archiveReport lists files of an archive that can not be used as tests.
+type archiveReport struct {
+	Unpaired  []string `json:"unpaired,omitempty"`  // input without output or the reverse
+	Empty     []string `json:"empty,omitempty"`     // empty files
+	Duplicate []string `json:"duplicate,omitempty"` // more than one input or output of a test
+	Ignored   []string `json:"ignored,omitempty"`   // files that are not tests
+}
+
+// OK reports whether the archive has no unusable test.
+func (r archiveReport) OK() bool {
+	return len(r.Unpaired) == 0 && len(r.Empty) == 0 && len(r.Duplicate) == 0
+}
+
+const (
+	notTest = iota
+	inputTest
+	outputTest
+)
+
+// classifyTest tells whether name is an input or output test, and the
+// name of its test case. Tests are paired by the extensions ".in" and
+// ".out" or ".ans", or by "input" and "output" directories.
+func classifyTest(name string) (string, int) {
+	dir, base := path.Split(name)
+	if strings.HasPrefix(base, ".") || strings.Contains(name, "__MACOSX/") {
+		return "", notTest
+	}
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	var dirs []string
+	kind := notTest
+	for _, d := range strings.Split(strings.Trim(dir, "/"), "/") {
+		switch strings.ToLower(d) {
+		case "input", "inputs":
+			kind = inputTest
+		case "output", "outputs", "answer", "answers":
+			kind = outputTest
+		case "":
+		default:
+			dirs = append(dirs, d)
+		}
+	}
+	if kind == notTest {
+		switch strings.ToLower(ext) {
+		case ".in":
+			kind = inputTest
+		case ".out", ".ans":
+			kind = outputTest
+		}
+	}
+	return path.Join(append(dirs, stem)...), kind
+}
+
+// pairTests pairs inputs with outputs in files, which maps file names to
+// their sizes, test cases are sorted by name in natural order.
+func pairTests(files map[string]int64) ([]testPair, archiveReport) {
+	var report archiveReport
+	pairs := make(map[string]*testPair)
+	for name, size := range files {
+		key, kind := classifyTest(name)
+		if kind == notTest {
+			report.Ignored = append(report.Ignored, name)
+			continue
+		}
+		if size == 0 {
+			report.Empty = append(report.Empty, name)
+		}
+		p, ok := pairs[key]
+		if !ok {
+			p = &testPair{Name: key}
+			pairs[key] = p
+		}
+		field := &p.Input
+		if kind == outputTest {
+			field = &p.Output
+		}
+		if *field != "" {
+			report.Duplicate = append(report.Duplicate, name)
+			continue
+		}
+		*field = name
+	}
+	var tests []testPair
+	for _, p := range pairs {
+		switch {
+		case p.Input == "":
+			report.Unpaired = append(report.Unpaired, p.Output)
+		case p.Output == "":
+			report.Unpaired = append(report.Unpaired, p.Input)
+		default:
+			tests = append(tests, *p)
+		}
+	}
+	sort.Sort(byNaturalName(tests))
+	for _, names := range [][]string{report.Unpaired, report.Empty, report.Duplicate, report.Ignored} {
+		sort.Strings(names)
+	}
+	return tests, report
+}
+
+type byNaturalName []testPair
+
+func (s byNaturalName) Len() int           { return len(s) }
+func (s byNaturalName) Less(i, j int) bool { return naturalLess(s[i].Name, s[j].Name) }
+func (s byNaturalName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// naturalLess compares a and b with runs of digits compared as numbers, so
+// "2" is less than "10".
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := leadingDigits(a), leadingDigits(b)
+		if da != "" && db != "" {
+			na, _ := strconv.ParseUint(da, 10, 64)
+			nb, _ := strconv.ParseUint(db, 10, 64)
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// crlfReader turns CRLF line endings into LF.
+type crlfReader struct {
+	r *bufio.Reader
+}
+
+func (c crlfReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if b == '\r' {
+			if next, err := c.r.Peek(1); err == nil && next[0] == '\n' {
+				continue
+			}
+		}
+		p[n] = b
+		n++
+		if c.r.Buffered() == 0 {
+			break
+		}
+	}
+	return n, nil
+}
+
+var errArchiveFormat = errors.New("archive is neither zip nor tar.gz")
+
+// extractArchive extracts the zip or tar.gz archive r into dir with line
+// endings normalised, returning the sizes of extracted files. At most limit
+// bytes are extracted.
+func extractArchive(r io.Reader, dir string, limit int64) (map[string]int64, error) {
+	return extractFiles(r, dir, limit, true)
+}
+
+// extractFiles extracts the zip or tar.gz archive r into dir like
+// extractArchive, files are kept as they are unless normalise.
+func extractFiles(r io.Reader, dir string, limit int64, normalise bool) (map[string]int64, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	files := make(map[string]int64)
+	extract := func(name string, r io.Reader) error {
+		name = path.Clean("/" + name)[1:]
+		if name == "" {
+			return nil
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(dst)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if normalise {
+			r = crlfReader{bufio.NewReader(r)}
+		}
+		n, err := io.Copy(f, io.LimitReader(r, limit+1))
+		if err != nil {
+			return err
+		}
+		if limit -= n; limit < 0 {
+			return ErrQuotaExceeded
+		}
+		files[name] = n
+		return nil
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		// zip needs random access, spool it first
+		f, err := ioutil.TempFile("", "archive-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		size, err := io.Copy(f, io.LimitReader(br, limit+1))
+		if err != nil {
+			return nil, err
+		}
+		if size > limit {
+			return nil, ErrQuotaExceeded
+		}
+		zr, err := zip.NewReader(f, size)
+		if err != nil {
+			return nil, err
+		}
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, err
+			}
+			err = extract(zf.Name, rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(gr)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+				continue
+			}
+			if err := extract(hdr.Name, tr); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errArchiveFormat
+	}
+	return files, nil
+}
+
+// putArchive replaces the test cases of a problem with test cases paired
+// from a zip or tar.gz archive body.
+func putArchive(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	dir, err := ioutil.TempDir("", "archive-")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer os.RemoveAll(dir)
+	files, err := extractArchive(c.Request.Body, dir, dataQuota)
+	if err == ErrQuotaExceeded {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tests, report := pairTests(files)
+	if !report.OK() || len(tests) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad tests in archive", "report": report})
+		return
+	}
+	var cases []model.TestCase
+	for _, pair := range tests {
+		var t model.TestCase
+		for name, file := range map[string]string{"input": pair.Input, "output": pair.Output} {
+			f, err := os.Open(filepath.Join(dir, filepath.FromSlash(file)))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			// the archive is already limited by the quota
+			digest, size, err := SaveBlobFrom("problems", f, dataQuota)
+			f.Close()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			t.Set(name, digest, size)
+		}
+		cases = append(cases, t)
+	}
+	if !validTests(c, problem, cases) {
+		return
+	}
+	if err := replaceCasesTx(problem, cases); err != nil {
+		c.JSON(replaceStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cases": cases, "names": tests, "report": report})
+}
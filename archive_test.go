@@ -0,0 +1,119 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var archiveFiles = map[string]string{
+	"tests/10.in":      "10\r\n",
+	"tests/10.ans":     "100\r\n",
+	"tests/2.in":       "2\n",
+	"tests/2.out":      "4\n",
+	"tests/3.in":       "3\n",
+	"tests/empty.in":   "",
+	"tests/empty.out":  "0\n",
+	"tests/README.md":  "squares",
+	"input/sample.txt": "1\n",
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractArchive(t *testing.T) {
+	for name, archive := range map[string][]byte{
+		"zip":    zipArchive(t, archiveFiles),
+		"tar.gz": tarGzArchive(t, archiveFiles),
+	} {
+		dir, err := ioutil.TempDir("", "archive")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		files, err := extractArchive(bytes.NewReader(archive), dir, 1<<20)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(files) != len(archiveFiles) {
+			t.Fatalf("%s: extract %d files, want %d", name, len(files), len(archiveFiles))
+		}
+		out, err := ioutil.ReadFile(filepath.Join(dir, "tests", "10.ans"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != "100\n" {
+			t.Fatalf("%s: CRLF is not normalised, get %q", name, out)
+		}
+		if _, err := extractArchive(bytes.NewReader(archive), dir, 4); err != ErrQuotaExceeded {
+			t.Fatalf("%s: get %v, want %v", name, err, ErrQuotaExceeded)
+		}
+	}
+	if _, err := extractArchive(bytes.NewReader([]byte("1 2\n")), "", 1<<20); err != errArchiveFormat {
+		t.Fatalf("get %v, want %v", err, errArchiveFormat)
+	}
+}
+
+func TestPairTests(t *testing.T) {
+	files := make(map[string]int64)
+	for name, content := range archiveFiles {
+		files[name] = int64(len(content))
+	}
+	files["output/sample.txt"] = 2
+	tests, report := pairTests(files)
+	want := []testPair{
+		{"sample", "input/sample.txt", "output/sample.txt"},
+		{"tests/2", "tests/2.in", "tests/2.out"},
+		{"tests/10", "tests/10.in", "tests/10.ans"},
+		{"tests/empty", "tests/empty.in", "tests/empty.out"},
+	}
+	if !reflect.DeepEqual(tests, want) {
+		t.Fatalf("get tests %v, want %v", tests, want)
+	}
+	wantReport := archiveReport{
+		Unpaired: []string{"tests/3.in"},
+		Empty:    []string{"tests/empty.in"},
+		Ignored:  []string{"tests/README.md"},
+	}
+	if !reflect.DeepEqual(report, wantReport) {
+		t.Fatalf("get report %+v, want %+v", report, wantReport)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+var (
+	// attachmentLimit is the limit of the size of an attachment in byte.
+	attachmentLimit int64 = 16 << 20
+	// attachmentQuota is the limit of the total size of attachments of a
+	// problem in byte.
+	attachmentQuota int64 = 64 << 20
+)
+
+// inlineTypes are the types of attachments shown in browsers, others are
+// downloaded.
+var inlineTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// attachmentsOf returns the attachments of a problem by name.
+func attachmentsOf(problemId int64) ([]model.Attachment, error) {
+	var attachments []model.Attachment
+	err := engine.Where("problem_id = ?", problemId).Asc("name").Find(&attachments)
+	return attachments, err
+}
+
+// getAttachment gets the attachment of problem id named name.
+func getAttachment(c *gin.Context, id interface{}, name string) (model.Attachment, bool) {
+	var attachment model.Attachment
+	has, err := engine.Where("problem_id = ? AND name = ?", id, name).Get(&attachment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return attachment, false
+	}
+	if !has {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return attachment, false
+	}
+	return attachment, true
+}
+
+// sniff returns the MIME type of the content of f by its first 512 bytes and
+// seeks f back to its start.
+func sniff(f *os.File) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// attachmentRef is a reference to an attachment in the HTML of a statement.
+var attachmentRef = regexp.MustCompile(`(href|src)="attachment:([A-Za-z0-9._-]+)"`)
+
+// linkAttachments replaces the references "attachment:name" in links and
+// images of rendered HTML with the URLs of the attachments of problem id.
+func linkAttachments(html string, problemId int64) string {
+	prefix := "/problem/" + strconv.FormatInt(problemId, 10) + "/attachments/"
+	return attachmentRef.ReplaceAllString(html, `$1="`+prefix+`$2"`)
+}
+
+// listAttachments lists the attachments of a problem.
+func listAttachments(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	attachments, err := attachmentsOf(problem.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"attachments": attachments})
+}
+
+// putAttachment adds or replaces an attachment of a problem with the body.
+// The file is written before the row like tests, but under its name, so a
+// replaced attachment is changed in place.
+func putAttachment(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	name := c.Param("name")
+	if err := model.CheckAttachmentName(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sums, err := engine.Where("problem_id = ? AND name <> ?", problem.Id, name).SumsInt(&model.Attachment{}, "size")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	limit := attachmentLimit
+	if left := attachmentQuota - sums[0]; left < limit {
+		limit = left
+	}
+	f, digest, size, err := spool(c.Request.Body, limit)
+	if err == ErrQuotaExceeded {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "attachment too large"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if size == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty attachment"})
+		return
+	}
+	attachment := model.Attachment{ProblemId: problem.Id, Name: name, Size: size, Hash: digest}
+	if err := saveAttachment(&attachment, f); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"attachment": attachment})
+}
+
+// storeAttachment stores the spooled content f of attachment and sets its
+// type.
+func storeAttachment(attachment *model.Attachment, f *os.File) error {
+	var err error
+	if attachment.MimeType, err = sniff(f); err != nil {
+		return err
+	}
+	if err := store.Put(attachment.Path(), f, attachment.Size); err != nil {
+		return err
+	}
+	return checkStored(attachment.Path(), attachment.Size)
+}
+
+// saveAttachment stores the spooled content f of attachment and adds or
+// replaces the attachment of the same name.
+func saveAttachment(attachment *model.Attachment, f *os.File) error {
+	if err := storeAttachment(attachment, f); err != nil {
+		return err
+	}
+	var old model.Attachment
+	has, err := engine.Where("problem_id = ? AND name = ?", attachment.ProblemId, attachment.Name).Get(&old)
+	if err == nil && has {
+		_, err = engine.Id(old.Id).Cols("mime_type", "size", "hash").Update(attachment)
+	} else if err == nil {
+		_, err = engine.InsertOne(attachment)
+	}
+	return err
+}
+
+// getAttachmentHandler downloads an attachment of a problem, images are
+// shown inline and other files are saved.
+func getAttachmentHandler(c *gin.Context) {
+	attachment, ok := getAttachment(c, c.Param("id"), c.Param("name"))
+	if !ok {
+		return
+	}
+	r, err := GetFile(attachment.Path())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer r.Close()
+	header := c.Writer.Header()
+	header.Set("Content-Type", attachment.MimeType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	disposition := "attachment"
+	if inlineTypes[strings.SplitN(attachment.MimeType, ";", 2)[0]] {
+		disposition = "inline"
+	}
+	header.Set("Content-Disposition", disposition+`; filename="`+attachment.Name+`"`)
+	c.Writer.WriteHeader(http.StatusOK)
+	io.Copy(c.Writer, r)
+}
+
+// deleteAttachment removes an attachment of a problem, the row first so the
+// file is never referred to after it is deleted.
+func deleteAttachment(c *gin.Context) {
+	attachment, ok := getAttachment(c, c.Param("id"), c.Param("name"))
+	if !ok {
+		return
+	}
+	if _, err := engine.Id(attachment.Id).Delete(&model.Attachment{}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Delete(attachment.Path()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
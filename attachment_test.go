@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+func TestSniff(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 600)
+	for content, want := range map[string]string{
+		png:                "image/png",
+		"<svg onload=x>":   "text/plain; charset=utf-8",
+		"1 2\n":            "text/plain; charset=utf-8",
+		"PK\x03\x04zipped": "application/zip",
+	} {
+		f, _, size, err := spool(strings.NewReader(content), attachmentLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mimeType, err := sniff(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mimeType != want {
+			t.Errorf("sniff %q as %s, want %s", content[:4], mimeType, want)
+		}
+		// the whole file is still read after sniffing
+		if rest, _ := ioutil.ReadAll(f); int64(len(rest)) != size {
+			t.Errorf("read %d bytes after sniffing, want %d", len(rest), size)
+		}
+		f.Close()
+	}
+	if _, _, _, err := spool(strings.NewReader(png), 100); err != ErrQuotaExceeded {
+		t.Errorf("spool %d bytes in 100 bytes with error %v", len(png), err)
+	}
+}
+
+func TestAttachmentName(t *testing.T) {
+	for name, ok := range map[string]bool{
+		"graph.png":     true,
+		"data_1-2.zip":  true,
+		".hidden":       false,
+		"../secret":     false,
+		"a b.png":       false,
+		"":              false,
+		"a/b.png":       false,
+		"图.png":         false,
+		"x.png\"onload": false,
+	} {
+		if err := model.CheckAttachmentName(name); (err == nil) != ok {
+			t.Errorf("check name %q with error %v", name, err)
+		}
+	}
+	if path := (model.Attachment{ProblemId: 3, Name: "graph.png"}).Path(); path != "problems/3/attachments/graph.png" {
+		t.Errorf("get path %s", path)
+	}
+}
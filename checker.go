@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// juryLimit is the limit of the size of a checker or interactor source in
+// byte.
+var juryLimit int64 = 1 << 20
+
+// saveJury saves the sources of the checker, interactor, validator and
+// generators of a new problem before the problem refers to them.
+func saveJury(problem *model.Problem) error {
+	for _, jury := range []struct{ source, digest *string }{
+		{&problem.Checker, &problem.CheckerHash},
+		{&problem.Interactor, &problem.InteractorHash},
+		{&problem.Validator, &problem.ValidatorHash},
+	} {
+		if *jury.source == "" {
+			continue
+		}
+		var err error
+		if *jury.digest, err = SaveBlob("problems", *jury.source); err != nil {
+			return err
+		}
+	}
+	return saveGenerators(problem.Generators, nil)
+}
+
+// putJury returns a handler replacing the jury program name of a problem,
+// whose digest is in column, with the C++ source in the body, the source is
+// compiled by the judger.
+func putJury(name, column string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		problem, ok := getProblem(c, c.Param("id"))
+		if !ok {
+			return
+		}
+		digest, size, err := SaveBlobFrom("problems", c.Request.Body, juryLimit)
+		if err == ErrQuotaExceeded {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": name + " too large"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if size == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "empty " + name})
+			return
+		}
+		// only column is updated
+		bean := &model.Problem{CheckerHash: digest, InteractorHash: digest, ValidatorHash: digest}
+		err = revise(problem.Id, func(s *xorm.Session) error {
+			_, err := s.Id(problem.Id).Cols(column).Update(bean)
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{name: digest})
+	}
+}
+
+// deleteJury returns a handler removing the jury program of a problem whose
+// digest is in column.
+func deleteJury(column string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		problem, ok := getProblem(c, c.Param("id"))
+		if !ok {
+			return
+		}
+		err := revise(problem.Id, func(s *xorm.Session) error {
+			_, err := s.Id(problem.Id).Cols(column).Update(&model.Problem{})
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	}
+}
+
+// putCompare sets the comparison mode of a problem, which is used when the
+// problem has no checker.
+func putCompare(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var req model.Problem
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.CheckCompare(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := revise(problem.Id, func(s *xorm.Session) error {
+		_, err := s.Id(problem.Id).Cols("compare", "epsilon").Update(&model.Problem{Compare: req.Compare, Epsilon: req.Epsilon})
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"compare": req.Compare, "epsilon": req.Epsilon})
+}
@@ -0,0 +1,119 @@
+// Package confine runs untrusted programs, like the solutions and jury
+// programs of problems, with resource limits set by prlimit of util-linux.
+// Programs run as a dedicated user without network if its id is set, which
+// needs root.
+package confine
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Limits are the limits of a program, zero is no limit.
+type Limits struct {
+	CPU    time.Duration // cpu time, rounded up to seconds
+	Memory int64         // address space in bytes
+	File   int64         // size of a file written in bytes
+	Procs  int           // processes of the user, only limited with a dedicated user
+}
+
+// User is a dedicated user programs run as, set by the environment
+// variables <prefix>_UID and <prefix>_GID.
+type User struct {
+	prefix string
+}
+
+var (
+	// Contestant runs solutions, SANDBOX_UID and SANDBOX_GID.
+	Contestant = User{"SANDBOX"}
+	// Jury runs jury programs, which read tests contestants must not,
+	// SANDBOX_JURY_UID and SANDBOX_JURY_GID.
+	Jury = User{"SANDBOX_JURY"}
+)
+
+// envKeys are the environment variables passed on to programs, the others
+// are dropped so secrets like DATABASE_URL are not leaked.
+var envKeys = []string{"PATH", "HOME", "TMPDIR", "LANG", "GOROOT", "GOPATH", "GOCACHE"}
+
+// credential returns the credential of u, nil if its uid is not set.
+func (u User) credential() (*syscall.Credential, error) {
+	uid := os.Getenv(u.prefix + "_UID")
+	if uid == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s_UID: %v", u.prefix, err)
+	}
+	gid := id
+	if g := os.Getenv(u.prefix + "_GID"); g != "" {
+		if gid, err = strconv.ParseUint(g, 10, 32); err != nil {
+			return nil, fmt.Errorf("%s_GID: %v", u.prefix, err)
+		}
+	}
+	return &syscall.Credential{Uid: uint32(id), Gid: uint32(gid)}, nil
+}
+
+// Command returns the command running name with args in limits as u, it
+// is killed once ctx is done.
+func (u User) Command(ctx context.Context, limits Limits, name string, args ...string) (*exec.Cmd, error) {
+	cred, err := u.credential()
+	if err != nil {
+		return nil, err
+	}
+	prlimit := []string{"--core=0"}
+	if limits.CPU > 0 {
+		prlimit = append(prlimit, fmt.Sprintf("--cpu=%d", (limits.CPU+time.Second-1)/time.Second))
+	}
+	if limits.Memory > 0 {
+		prlimit = append(prlimit, fmt.Sprintf("--as=%d", limits.Memory))
+	}
+	if limits.File > 0 {
+		prlimit = append(prlimit, fmt.Sprintf("--fsize=%d", limits.File))
+	}
+	// the processes of other users than the dedicated one are not only of
+	// the program
+	if limits.Procs > 0 && cred != nil {
+		prlimit = append(prlimit, fmt.Sprintf("--nproc=%d", limits.Procs))
+	}
+	prlimit = append(prlimit, "--", name)
+	cmd := exec.CommandContext(ctx, "prlimit", append(prlimit, args...)...)
+	cmd.Env = []string{}
+	for _, key := range envKeys {
+		if value, ok := os.LookupEnv(key); ok {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	if cred != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Credential: cred,
+			// a new network namespace has no network but loopback
+			Cloneflags: syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		}
+	}
+	return cmd, nil
+}
+
+// TempDir creates a new temporary directory like ioutil.TempDir, which
+// programs run as u can write to. It is owned by u if its uid is set.
+func (u User) TempDir(prefix string) (string, error) {
+	cred, err := u.credential()
+	if err != nil {
+		return "", err
+	}
+	dir, err := ioutil.TempDir("", prefix)
+	if err != nil || cred == nil {
+		return dir, err
+	}
+	if err := os.Chown(dir, int(cred.Uid), int(cred.Gid)); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
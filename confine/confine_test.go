@@ -0,0 +1,56 @@
+package confine
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommand(t *testing.T) {
+	os.Setenv("CONFINE_SECRET", "secret")
+	defer os.Unsetenv("CONFINE_SECRET")
+	cmd, err := Contestant.Command(context.Background(), Limits{}, "sh", "-c", "echo \"$CONFINE_SECRET\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(out)) != "" {
+		t.Errorf("environment %q is passed on", out)
+	}
+
+	cmd, err = Contestant.Command(context.Background(), Limits{CPU: time.Second}, "sh", "-c", "while :; do :; done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := cmd.Run(); err == nil {
+		t.Error("a program runs out of cpu time without error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("a program runs %v over its cpu time", d)
+	}
+
+	dir, err := Contestant.TempDir("confine-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "out")
+	cmd, err = Contestant.Command(context.Background(), Limits{File: 1000}, "sh", "-c", "head -c 2000 /dev/zero > "+file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Run(); err == nil {
+		t.Error("a program writes over its file size without error")
+	}
+	if b, err := ioutil.ReadFile(file); err != nil || len(b) > 1000 {
+		t.Errorf("a program writes %d bytes over its file size: %v", len(b), err)
+	}
+}
@@ -1,67 +1,95 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"io/ioutil"
 	"os"
 	"strings"
 
-	"qiniupkg.com/api.v7/conf"
-	"qiniupkg.com/api.v7/kodo"
-	"qiniupkg.com/api.v7/kodocli"
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
 )
 
 var (
-	bucket string
-	domain string
+	store storage.Storage
 )
 
-func init() {
-
-	conf.ACCESS_KEY = os.Getenv("QINIU_ACCESS_KEY")
-	conf.SECRET_KEY = os.Getenv("QINIU_SECRET_KEY")
-	bucket = os.Getenv("QINIU_BUCKET")
-	domain = os.Getenv("QINIU_DOMAIN")
-
+// SaveBlob saves content under its digest in dir and returns the digest,
+// content already saved is not uploaded again.
+func SaveBlob(dir string, content string) (string, error) {
+	digest := storage.Digest([]byte(content))
+	key := model.BlobPath(dir, digest)
+	if err := storage.PutIfAbsent(store, key, strings.NewReader(content), int64(len(content)), digest); err != nil {
+		return "", err
+	}
+	return digest, checkStored(key, int64(len(content)))
 }
 
-// ret type
-type PutRet struct {
-	Hash string `json:"hash"`
-	Key  string `json:"key"`
+// checkStored makes sure key of size bytes is readable from the storage
+// before a row refers to it, files are always written before rows so a
+// problem or code never points at missing files.
+func checkStored(key string, size int64) error {
+	info, err := store.Stat(key)
+	if err != nil {
+		return fmt.Errorf("check %s: %v", key, err)
+	}
+	if info.Size != size {
+		return fmt.Errorf("check %s: size %d, want %d", key, info.Size, size)
+	}
+	return nil
 }
 
-func SaveFile(key string, content string) error {
-	// This a a workaround, for test should not write real file
-	// TODO: abstract fs interface
+// ErrQuotaExceeded is returned when the data of a problem exceeds its quota.
+var ErrQuotaExceeded = errors.New("problem data quota exceeded")
 
-	if inTest {
-		return nil
-	}
-	c := kodo.New(0, nil)
-	policy := &kodo.PutPolicy{
-		Scope: bucket,
-	}
-	var ret PutRet
-	token := c.MakeUptoken(policy)
-	uploader := kodocli.NewUploader(0, nil)
-	err := uploader.Put(nil, &ret, token, key, strings.NewReader(content), int64(len(content)), nil)
+// SaveBlobFrom is SaveBlob reading at most limit bytes from r, the content
+// is hashed while spooled to a temporary file so it is never held in memory.
+func SaveBlobFrom(dir string, r io.Reader, limit int64) (digest string, size int64, err error) {
+	f, digest, size, err := spool(r, limit)
 	if err != nil {
-		return err
+		return "", 0, err
 	}
-	return nil
+	defer os.Remove(f.Name())
+	defer f.Close()
+	key := model.BlobPath(dir, digest)
+	if err := storage.PutIfAbsent(store, key, f, size, digest); err != nil {
+		return "", 0, err
+	}
+	return digest, size, checkStored(key, size)
 }
 
-// GetFIle gets a file, it is the caller's reponsibility to close file.
-func GetFile(key string) (io.ReadCloser, error) {
-	baseUrl := kodo.MakeBaseUrl(domain, key) // download url
-	resp, err := http.Get(baseUrl)
+// spool copies at most limit bytes from r to a temporary file and returns it
+// at its start with the digest and size of the content. ErrQuotaExceeded is
+// returned if r has more. The caller removes the file.
+func spool(r io.Reader, limit int64) (*os.File, string, int64, error) {
+	f, err := ioutil.TempFile("", "blob-")
+	if err != nil {
+		return nil, "", 0, err
+	}
+	fail := func(err error) (*os.File, string, int64, error) {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, "", 0, err
+	}
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(r, limit+1))
 	if err != nil {
-		return nil, err
+		return fail(err)
 	}
-	if resp.StatusCode/100 != 2 {
-		return nil, fmt.Errorf("Status code %d", resp.StatusCode)
+	if size > limit {
+		return fail(ErrQuotaExceeded)
 	}
-	return resp.Body, nil
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	return f, hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// GetFile gets a file, it is the caller's reponsibility to close file.
+func GetFile(key string) (io.ReadCloser, error) {
+	return store.Get(key)
 }
@@ -1,16 +1,29 @@
 package main
 
 import (
+	"io"
 	"io/ioutil"
+	"strings"
 	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
 )
 
-func TestSimple(t *testing.T) {
-	err := SaveFile("test", "test")
+func TestSaveBlob(t *testing.T) {
+	store = storage.NewMemory()
+	a, err := SaveBlob("problems", "1 2\n")
 	if err != nil {
 		t.Fatal(err)
 	}
-	r, err := GetFile("test")
+	b, err := SaveBlob("problems", "1 2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Fatalf("same content get different digests %s %s", a, b)
+	}
+	r, err := GetFile(model.BlobPath("problems", a))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -19,7 +32,47 @@ func TestSimple(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if string(out) != "test" {
-		t.Fatalf("output not equal get %s\n", out)
+	if string(out) != "1 2\n" {
+		t.Fatalf("get %q from the saved blob", out)
+	}
+	infos, err := store.List("problems/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("duplicate content saved %d times", len(infos))
+	}
+}
+
+func TestSaveBlobFrom(t *testing.T) {
+	store = storage.NewMemory()
+	digest, size, err := SaveBlobFrom("problems", strings.NewReader("1 2\n"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 4 || digest != storage.Digest([]byte("1 2\n")) {
+		t.Fatalf("get digest %s size %d", digest, size)
+	}
+	if _, err := store.Stat(model.BlobPath("problems", digest)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := SaveBlobFrom("problems", strings.NewReader("1 2 3\n"), 4); err != ErrQuotaExceeded {
+		t.Fatalf("get %v, want %v", err, ErrQuotaExceeded)
+	}
+}
+
+// lostStorage loses everything put.
+type lostStorage struct {
+	storage.Storage
+}
+
+func (lostStorage) Put(key string, r io.Reader, size int64) error {
+	return nil
+}
+
+func TestSaveBlobLost(t *testing.T) {
+	store = lostStorage{storage.NewMemory()}
+	if _, err := SaveBlob("codes", "int main(){}"); err == nil {
+		t.Fatal("lost source is reported saved")
 	}
 }
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+var gcCommand = cli.Command{
+	Name:  "gc",
+	Usage: "delete stored tests and sources no problem or code refers to",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "only report orphans",
+		},
+		cli.DurationFlag{
+			Name:  "grace",
+			Value: 24 * time.Hour,
+			Usage: "keep orphans younger than this, they may belong to requests in flight",
+		},
+	},
+	Action: gc,
+}
+
+// gcPrefixes are the storage prefixes collected.
+var gcPrefixes = []string{"problems/", "codes/", "uploads/", "transcripts/"}
+
+func gc(c *cli.Context) error {
+	openDB()
+	s, err := storage.FromEnv()
+	if err != nil {
+		return err
+	}
+	// blobs in use are rewritten once older than storage.BlobRefresh
+	if c.Duration("grace") <= storage.BlobRefresh {
+		return fmt.Errorf("grace must be longer than %v", storage.BlobRefresh)
+	}
+	before := time.Now().Add(-c.Duration("grace"))
+	expired, err := expireUploads(before, c.Bool("dry-run"))
+	if err != nil {
+		return err
+	}
+	referenced, err := referencedKeys()
+	if err != nil {
+		return err
+	}
+	if err := referenceUploads(s, referenced); err != nil {
+		return err
+	}
+	orphans, err := findOrphans(s, referenced, before)
+	if err != nil {
+		return err
+	}
+	var (
+		count int
+		total int64
+	)
+	for _, orphan := range orphans {
+		if !c.Bool("dry-run") {
+			deleted, err := deleteOrphan(s, orphan.Key, before)
+			if err != nil {
+				return err
+			}
+			if !deleted {
+				continue
+			}
+		}
+		count++
+		total += orphan.Size
+		fmt.Printf("%s\t%d\t%s\n", orphan.Key, orphan.Size, orphan.ModTime.Format(time.RFC3339))
+	}
+	if c.Bool("dry-run") {
+		fmt.Printf("%d stale uploads and %d orphans of %d bytes found\n", expired, count, total)
+	} else {
+		fmt.Printf("%d stale uploads and %d orphans of %d bytes deleted\n", expired, count, total)
+	}
+	return nil
+}
+
+// deleteOrphan deletes the orphan key unless it is gone or written again
+// since the given time, as a blob reused by a new row is. It reports
+// whether key is deleted.
+func deleteOrphan(s storage.Storage, key string, before time.Time) (bool, error) {
+	info, err := s.Stat(key)
+	if err == storage.ErrNotExist {
+		return false, nil
+	}
+	if err != nil || !info.ModTime.Before(before) {
+		return false, err
+	}
+	return true, s.Delete(key)
+}
+
+// expireUploads deletes uploads started before the given time, abandoned
+// or waiting too long for the other file of their test case, so their files
+// are orphans. A dry run only counts them.
+func expireUploads(before time.Time, dryRun bool) (int64, error) {
+	if dryRun {
+		return engine.Where("created_at < ?", before).Count(&model.Upload{})
+	}
+	return engine.Where("created_at < ?", before).Delete(&model.Upload{})
+}
+
+// referenceUploads adds the files of uploads in flight in s to referenced,
+// their chunks and the file of a new test case waiting for the other.
+func referenceUploads(s storage.Storage, referenced map[string]string) error {
+	var uploads []model.Upload
+	if err := engine.Find(&uploads); err != nil {
+		return err
+	}
+	for _, u := range uploads {
+		if u.Hash != "" {
+			referenced[model.BlobPath("problems", u.Hash)] = u.Hash
+		}
+		chunks, err := s.List(u.ChunkPrefix())
+		if err != nil {
+			return err
+		}
+		for _, chunk := range chunks {
+			referenced[chunk.Key] = ""
+		}
+	}
+	return nil
+}
+
+// referencedKeys returns the storage keys problems, codes and their results
+// refer to, mapped to their SHA-256 digests or "" for files which are not
+// content addressed.
+func referencedKeys() (map[string]string, error) {
+	referenced := make(map[string]string)
+	err := engine.Iterate(new(model.Problem), func(i int, bean interface{}) error {
+		problem := bean.(*model.Problem)
+		referenced[problem.InputTestPath()] = problem.InputHash
+		referenced[problem.OutputTestPath()] = problem.OutputHash
+		if problem.CheckerHash != "" {
+			referenced[problem.CheckerPath()] = problem.CheckerHash
+		}
+		if problem.InteractorHash != "" {
+			referenced[problem.InteractorPath()] = problem.InteractorHash
+		}
+		if problem.ValidatorHash != "" {
+			referenced[problem.ValidatorPath()] = problem.ValidatorHash
+		}
+		for _, g := range problem.Generators {
+			referenced[g.Path()] = g.Hash
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = engine.Iterate(new(model.TestCase), func(i int, bean interface{}) error {
+		t := bean.(*model.TestCase)
+		referenced[t.InputPath()] = t.InputHash
+		referenced[t.OutputPath()] = t.OutputHash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// old revisions keep their files for rollback
+	err = engine.Iterate(new(model.ProblemRevision), func(i int, bean interface{}) error {
+		r := bean.(*model.ProblemRevision)
+		problem := r.Problem()
+		for digest, path := range map[string]string{
+			problem.InputHash:      problem.InputTestPath(),
+			problem.OutputHash:     problem.OutputTestPath(),
+			problem.CheckerHash:    problem.CheckerPath(),
+			problem.InteractorHash: problem.InteractorPath(),
+			problem.ValidatorHash:  problem.ValidatorPath(),
+		} {
+			if digest != "" {
+				referenced[path] = digest
+			}
+		}
+		for _, g := range problem.Generators {
+			referenced[g.Path()] = g.Hash
+		}
+		for _, t := range r.Cases {
+			referenced[t.InputPath()] = t.InputHash
+			referenced[t.OutputPath()] = t.OutputHash
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = engine.Iterate(new(model.Attachment), func(i int, bean interface{}) error {
+		a := bean.(*model.Attachment)
+		// replaced in place, so not checked against its digest
+		referenced[a.Path()] = ""
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = engine.Iterate(new(model.Solution), func(i int, bean interface{}) error {
+		s := bean.(*model.Solution)
+		referenced[s.SourcePath()] = s.SourceHash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = engine.Iterate(new(model.Code), func(i int, bean interface{}) error {
+		code := bean.(*model.Code)
+		referenced[code.SourcePath()] = code.SourceHash
+		for nth, digest := range code.OutputHashes {
+			referenced[code.OutputPath(nth)] = digest
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = engine.Where("transcript <> ''").Iterate(new(model.CodeCaseResult), func(i int, bean interface{}) error {
+		referenced[bean.(*model.CodeCaseResult).Transcript] = ""
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return referenced, nil
+}
+
+// findOrphans lists objects under gcPrefixes which are not referenced and
+// were modified before the given time.
+func findOrphans(s storage.Storage, referenced map[string]string, before time.Time) ([]storage.Info, error) {
+	var orphans []storage.Info
+	for _, prefix := range gcPrefixes {
+		infos, err := s.List(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if _, ok := referenced[info.Key]; !ok && info.ModTime.Before(before) {
+				orphans = append(orphans, info)
+			}
+		}
+	}
+	return orphans, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+func TestFindOrphans(t *testing.T) {
+	store = storage.NewMemory()
+	problem := model.Problem{Id: 1}
+	code := model.Code{Id: 1, Language: model.C}
+	for _, key := range []string{problem.InputTestPath(), problem.OutputTestPath(), code.SourcePath(), "codes/2.c", "uploads/x/0", "uploads/y/0"} {
+		if err := store.Put(key, strings.NewReader("test"), 4); err != nil {
+			t.Fatal(err)
+		}
+	}
+	referenced := map[string]string{
+		problem.InputTestPath():  "",
+		problem.OutputTestPath(): "",
+		code.SourcePath():        "",
+		"uploads/y/0":            "",
+	}
+	orphans, err := findOrphans(store, referenced, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// chunks of abandoned uploads are orphans too
+	if len(orphans) != 2 || orphans[0].Key != "codes/2.c" || orphans[1].Key != "uploads/x/0" {
+		t.Fatalf("get orphans %v", orphans)
+	}
+	orphans, err = findOrphans(store, referenced, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 0 {
+		t.Fatalf("orphans in grace period are found: %v", orphans)
+	}
+}
+
+func TestDeleteOrphan(t *testing.T) {
+	s := storage.NewMemory()
+	for _, key := range []string{"codes/1.c", "codes/2.c"} {
+		if err := s.Put(key, strings.NewReader("test"), 4); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := time.Now().Add(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	// a blob reused meanwhile is written again
+	if err := s.Put("codes/2.c", strings.NewReader("test"), 4); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		key     string
+		deleted bool
+	}{
+		{"codes/1.c", true},
+		{"codes/2.c", false},
+		{"codes/3.c", false},
+	} {
+		deleted, err := deleteOrphan(s, c.key, before)
+		if err != nil || deleted != c.deleted {
+			t.Errorf("delete %s get %v, %v, want %v", c.key, deleted, err, c.deleted)
+		}
+	}
+	if _, err := s.Stat("codes/2.c"); err != nil {
+		t.Errorf("a blob written again is deleted: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// saveGenerators saves the sources of generators before the problem refers
+// to them, a generator without source keeps the digest of the generator of
+// the same name in old.
+func saveGenerators(generators, old []model.Generator) error {
+	hashes := make(map[string]string)
+	for _, g := range old {
+		hashes[g.Name] = g.Hash
+	}
+	for i := range generators {
+		g := &generators[i]
+		if g.Source == "" {
+			if g.Hash = hashes[g.Name]; g.Hash == "" {
+				return fmt.Errorf("generator %s has no source", g.Name)
+			}
+			continue
+		}
+		if int64(len(g.Source)) > juryLimit {
+			return fmt.Errorf("generator %s too large", g.Name)
+		}
+		digest, err := SaveBlob("problems", g.Source)
+		if err != nil {
+			return err
+		}
+		g.Hash, g.Source = digest, ""
+	}
+	return nil
+}
+
+// putGenerators replaces the generators and the script of a problem.
+func putGenerators(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var req model.Problem
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.CheckGenerators(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := saveGenerators(req.Generators, problem.Generators); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := revise(problem.Id, func(s *xorm.Session) error {
+		_, err := s.Id(problem.Id).Cols("generators", "script").Update(&req)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"generators": req.Generators, "script": req.Script})
+}
+
+// generate replaces the test cases of a problem with the inputs its script
+// generates and the outputs of the solution in the body on them, or of the
+// main solution without a body. Inputs are validated like uploaded tests.
+func generate(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	// the main solution answers without a solution in the body
+	var solution model.Code
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&solution); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := solution.Init(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	lines, err := problem.ParseScript()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(lines) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty script"})
+		return
+	}
+	dir, err := confine.Contestant.TempDir("generate-")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer os.RemoveAll(dir)
+	var binary string
+	if solution.Source == "" {
+		if binary, err = buildMain(problem.Id, dir); err != nil {
+			c.JSON(answerStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		source := filepath.Join(dir, "main."+strings.ToLower(solution.Language.String()))
+		binary = filepath.Join(dir, "main")
+		if err := ioutil.WriteFile(source, []byte(solution.Source), 0644); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := compileSolution(solution.Language, source, binary); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	generators := make(map[string]string)
+	for _, g := range problem.Generators {
+		if generators[g.Name], err = compileJury(g.Path(), g.Hash); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var (
+		cases []model.TestCase
+		total int64
+	)
+	for i, line := range lines {
+		var t model.TestCase
+		input, output := filepath.Join(dir, "input"), filepath.Join(dir, "output")
+		if err := runTo(generators[line.Generator], line.Args, "", input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("generate test %d: %v", i+1, err)})
+			return
+		}
+		if err := runTo(binary, nil, input, output); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("solution on test %d: %v", i+1, err)})
+			return
+		}
+		for name, file := range map[string]string{"input": input, "output": output} {
+			f, err := os.Open(file)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			digest, size, err := SaveBlobFrom("problems", f, dataQuota-total)
+			f.Close()
+			if err == ErrQuotaExceeded {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			total += size
+			t.Set(name, digest, size)
+		}
+		cases = append(cases, t)
+	}
+	if !validTests(c, problem, cases) {
+		return
+	}
+	if err := replaceCasesTx(problem, cases); err != nil {
+		c.JSON(replaceStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cases": cases})
+}
+
+// runTo runs binary with args on the file input, or nothing if input is
+// empty, and writes its output to the file output.
+func runTo(binary string, args []string, input, output string) error {
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if input == "" {
+		return runJury(binary, args, nil, out)
+	}
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return runJury(binary, args, in, out)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"container/list"
+	"expvar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var cacheStats = expvar.NewMap("cache")
+
+// Cache is a bounded on-disk LRU cache of immutable files such as content
+// addressed tests and compiled binaries, the least recently used files are
+// evicted once the total size exceeds the limit.
+type Cache struct {
+	dir string
+	max int64
+
+	mu       sync.Mutex
+	size     int64
+	lru      *list.List // of *cacheEntry, most recently used at front
+	entries  map[string]*list.Element
+	inflight map[string]*sync.WaitGroup
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+// NewCache creates a cache of at most max bytes in dir, files left in dir by
+// a previous run are kept. Only the judger can access dir, so programs run
+// by the dedicated user of confine can not read cached tests.
+func NewCache(dir string, max int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	// dir may be made by an earlier version
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		dir:      dir,
+		max:      max,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		inflight: make(map[string]*sync.WaitGroup),
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byModTime(infos))
+	for _, fi := range infos {
+		if fi.IsDir() || filepath.Ext(fi.Name()) == ".tmp" {
+			os.RemoveAll(filepath.Join(dir, fi.Name()))
+			continue
+		}
+		c.add(fi.Name(), fi.Size())
+	}
+	c.evict()
+	return c, nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+// add records key as the most recently used entry, c.mu must be held.
+func (c *Cache) add(key string, size int64) {
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, size: size})
+	c.size += size
+	cacheStats.Add("bytes", size)
+}
+
+// evict removes the least recently used entries until the cache fits its
+// limit, c.mu must be held.
+func (c *Cache) evict() {
+	for c.size > c.max && c.lru.Len() > 0 {
+		e := c.lru.Remove(c.lru.Back()).(*cacheEntry)
+		delete(c.entries, e.key)
+		c.size -= e.size
+		os.Remove(c.path(e.key))
+		cacheStats.Add("evictions", 1)
+		cacheStats.Add("bytes", -e.size)
+	}
+}
+
+// Link makes dst a copy of the cached file key, fill is called to write the
+// file to its argument path on a miss. Concurrent misses of the same key
+// call fill only once. An empty dst only makes sure key is cached.
+func (c *Cache) Link(key, dst string, fill func(path string) error) error {
+	for {
+		c.mu.Lock()
+		if elem, ok := c.entries[key]; ok {
+			c.touch(elem)
+			// link while holding the lock so the file is not evicted meanwhile
+			err := link(c.path(key), dst)
+			c.mu.Unlock()
+			cacheStats.Add("hits", 1)
+			return err
+		}
+		if wg, ok := c.inflight[key]; ok {
+			c.mu.Unlock()
+			wg.Wait()
+			continue
+		}
+		wg := new(sync.WaitGroup)
+		wg.Add(1)
+		c.inflight[key] = wg
+		c.mu.Unlock()
+		cacheStats.Add("misses", 1)
+
+		err := c.fill(key, fill)
+		c.mu.Lock()
+		delete(c.inflight, key)
+		wg.Done()
+		if err == nil {
+			err = link(c.path(key), dst)
+		}
+		c.mu.Unlock()
+		if os.IsNotExist(err) {
+			// larger than the whole cache and evicted at once
+			err = fill(dst)
+		}
+		return err
+	}
+}
+
+// Lookup links the cached file key to dst and reports whether key is cached.
+func (c *Cache) Lookup(key, dst string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		cacheStats.Add("misses", 1)
+		return false
+	}
+	if err := link(c.path(key), dst); err != nil {
+		return false
+	}
+	c.touch(elem)
+	cacheStats.Add("hits", 1)
+	return true
+}
+
+// Put adds the file src to the cache as key.
+func (c *Cache) Put(key, src string) error {
+	return c.fill(key, func(path string) error { return link(src, path) })
+}
+
+// touch marks the entry of elem as the most recently used, the modification
+// time keeps the order when the cache is reloaded, c.mu must be held.
+func (c *Cache) touch(elem *list.Element) {
+	c.lru.MoveToFront(elem)
+	now := time.Now()
+	os.Chtimes(c.path(elem.Value.(*cacheEntry).key), now, now)
+}
+
+func (c *Cache) fill(key string, fill func(path string) error) error {
+	tmp := c.path(key) + ".tmp"
+	if err := fill(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	fi, err := os.Stat(tmp)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path(key)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		// replaced by a concurrent Put
+		e := elem.Value.(*cacheEntry)
+		c.lru.Remove(elem)
+		c.size -= e.size
+		cacheStats.Add("bytes", -e.size)
+	}
+	c.add(key, fi.Size())
+	c.evict()
+	return nil
+}
+
+// link hard links src to dst, falling back to copying. Nothing is done if
+// dst is empty.
+func link(src, dst string) error {
+	if dst == "" {
+		return nil
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+type byModTime []os.FileInfo
+
+func (s byModTime) Len() int           { return len(s) }
+func (s byModTime) Less(i, j int) bool { return s[i].ModTime().Before(s[j].ModTime()) }
+func (s byModTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeFill(content string, calls *int) func(string) error {
+	return func(path string) error {
+		*calls++
+		return ioutil.WriteFile(path, []byte(content), 0644)
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := NewCache(filepath.Join(dir, "cache"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0700 {
+		t.Fatalf("cache directory mode %v, want %v", fi.Mode().Perm(), os.FileMode(0700))
+	}
+	var calls int
+	for i := 0; i < 2; i++ {
+		dst := filepath.Join(dir, "a")
+		os.Remove(dst)
+		if err := c.Link("a", dst, writeFill("aaaa", &calls)); err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != "aaaa" {
+			t.Fatalf("get %q, want %q", out, "aaaa")
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("fill called %d times, want 1", calls)
+	}
+
+	// a is used after b, so b is evicted when c does not fit
+	if err := c.Link("b", "", writeFill("bbbb", &calls)); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Lookup("a", "") {
+		t.Fatal("a is not cached")
+	}
+	if err := c.Link("c", "", writeFill("cccc", &calls)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Lookup("b", "") {
+		t.Fatal("least recently used b is not evicted")
+	}
+	if !c.Lookup("a", "") || !c.Lookup("c", "") {
+		t.Fatal("recently used entries are evicted")
+	}
+
+	// entries survive a restart
+	c, err = NewCache(filepath.Join(dir, "cache"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Lookup("a", "") || !c.Lookup("c", "") {
+		t.Fatal("entries are lost after reload")
+	}
+}
+
+func TestCacheConcurrentMiss(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := NewCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu    sync.Mutex
+		calls int
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.Link("test", "", func(path string) error {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				return ioutil.WriteFile(path, []byte("test"), 0644)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("fill called %d times, want 1", calls)
+	}
+}
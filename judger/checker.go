@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/jury"
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+var (
+	// checkerTimeout is the limit of time a checker runs on a test case
+	checkerTimeout = 10 * time.Second
+	// juryFileLimit is the limit of size of a file written by a checker or
+	// an interactor in byte
+	juryFileLimit = int64(16 << 20)
+)
+
+// exit codes of testlib checkers and interactors
+const (
+	testlibOK     = 0
+	testlibWA     = 1
+	testlibPE     = 2
+	testlibFail   = 3
+	testlibDirt   = 4
+	testlibPoints = 7
+	// _pc(n) exits with testlibPartially+n, n is a percentage of the score
+	testlibPartially = 16
+)
+
+// compileJury makes dst the compiled checker or interactor whose source is
+// the file key with digest, jury programs are compiled once and cached by
+// the digest of their source. Tests are readable by the jury user only, so
+// the directory of dst is made by confine.Jury.TempDir.
+func compileJury(key, digest, dst string) error {
+	return cache.Link("jury-"+digest, dst, func(path string) error {
+		err := jury.Compile(path, func(source string) error {
+			return fetch(key, digest, source)
+		})
+		if err != nil {
+			return fmt.Errorf("compile %s: %v", key, err)
+		}
+		return nil
+	})
+}
+
+// juryLimits are the limits of a checker or an interactor running for
+// timeout.
+func juryLimits(timeout time.Duration) confine.Limits {
+	return confine.Limits{CPU: timeout, Memory: jury.Memory, File: juryFileLimit, Procs: jury.Procs}
+}
+
+// check runs checker with the input, the contestant output and the jury
+// answer like a testlib checker, and returns the verdict, the score in
+// [0, 1] and the message of the checker.
+func check(checker, input, output, answer string) (model.JudgeResult, float64, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkerTimeout)
+	defer cancel()
+	cmd, err := jury.Command(ctx, juryLimits(checkerTimeout), checker, input, output, answer)
+	if err != nil {
+		return model.SystemError, 0, "", err
+	}
+	var stderr jury.Message
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	message := stderr.String()
+	code := 0
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok || ctx.Err() != nil {
+			return model.SystemError, 0, message, fmt.Errorf("run checker: %v", err)
+		}
+		code = exitErr.ExitCode()
+	}
+	status, score, err := verdictOf(code, message)
+	return status, score, message, err
+}
+
+// verdictOf returns the verdict and score of a testlib exit code and message.
+func verdictOf(code int, message string) (model.JudgeResult, float64, error) {
+	switch code {
+	case testlibOK:
+		return model.Accept, 1, nil
+	case testlibWA, testlibDirt:
+		return model.WrongAnswer, 0, nil
+	case testlibPE:
+		return model.PresentationError, 0, nil
+	case testlibPoints:
+		// quitp writes "points", the points in [0, 1] and the message
+		fields := strings.Fields(strings.TrimPrefix(message, "points "))
+		if len(fields) == 0 {
+			return model.SystemError, 0, fmt.Errorf("checker gives no points")
+		}
+		score, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return model.SystemError, 0, fmt.Errorf("checker gives points %q", fields[0])
+		}
+		status, score := partial(score)
+		return status, score, nil
+	case testlibFail:
+		return model.SystemError, 0, fmt.Errorf("checker failed: %s", message)
+	}
+	if code >= testlibPartially {
+		status, score := partial(float64(code-testlibPartially) / 100)
+		return status, score, nil
+	}
+	return model.SystemError, 0, fmt.Errorf("checker exits with %d: %s", code, message)
+}
+
+// partial returns the verdict of a score given by a checker, clamped to
+// [0, 1].
+func partial(score float64) (model.JudgeResult, float64) {
+	if score >= 1 {
+		return model.Accept, 1
+	}
+	if score < 0 {
+		score = 0
+	}
+	return model.WrongAnswer, score
+}
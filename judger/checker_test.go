@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+func TestVerdictOf(t *testing.T) {
+	for _, c := range []struct {
+		code    int
+		message string
+		status  model.JudgeResult
+		score   float64
+		err     bool
+	}{
+		{testlibOK, "ok 3 numbers", model.Accept, 1, false},
+		{testlibWA, "wrong answer 1st numbers differ", model.WrongAnswer, 0, false},
+		{testlibPE, "extra tokens", model.PresentationError, 0, false},
+		{testlibDirt, "", model.WrongAnswer, 0, false},
+		// as written by quitp and _pc of testlib
+		{testlibPoints, "points 0.25 partially", model.WrongAnswer, 0.25, false},
+		{testlibPoints, "points 0.5", model.WrongAnswer, 0.5, false},
+		{testlibPoints, "points 1 all", model.Accept, 1, false},
+		{testlibPoints, "", model.SystemError, 0, true},
+		{testlibPoints, "points many", model.SystemError, 0, true},
+		{testlibPartially + 40, "partially correct (40) half", model.WrongAnswer, 0.4, false},
+		{testlibPartially + 100, "partially correct (100) all", model.Accept, 1, false},
+		{testlibFail, "answer is wrong", model.SystemError, 0, true},
+		{12, "", model.SystemError, 0, true},
+	} {
+		status, score, err := verdictOf(c.code, c.message)
+		if status != c.status || score != c.score || (err != nil) != c.err {
+			t.Errorf("verdictOf(%d, %q) = %v, %v, %v", c.code, c.message, status, score, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir, err := confine.Jury.TempDir("checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the checker accepts an output equal to the answer ignoring case
+	checker := filepath.Join(dir, "checker")
+	script := "#!/bin/sh\nif [ \"$(tr a-z A-Z < $2)\" = \"$(tr a-z A-Z < $3)\" ]; then echo ok >&2; exit 0; fi\necho wrong >&2; exit 1\n"
+	if err := ioutil.WriteFile(checker, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"input": "", "answer": "YES\n", "yes": "yes\n", "no": "no\n"}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for output, want := range map[string]model.JudgeResult{"yes": model.Accept, "no": model.WrongAnswer} {
+		status, _, message, err := check(checker, filepath.Join(dir, "input"), filepath.Join(dir, output), filepath.Join(dir, "answer"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != want {
+			t.Errorf("check %s: get %v (%s), want %v", output, status, message, want)
+		}
+	}
+}
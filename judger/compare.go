@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// compare compares output with the answer file by the comparison mode of
+// problem, an output which has the same tokens as the answer but differs in
+// whitespace is a presentation error in exact and trailing modes.
+func compare(problem model.Problem, output []byte, answer string) (model.JudgeResult, string, error) {
+	f, err := os.Open(answer)
+	if err != nil {
+		return model.SystemError, "", err
+	}
+	defer f.Close()
+	var (
+		ok      bool
+		message string
+	)
+	switch problem.Compare {
+	case "", model.CompareExact:
+		ok, err = sameBytes(bytes.NewReader(output), f)
+	case model.CompareTrailing:
+		ok, message, err = sameLines(bytes.NewReader(output), f)
+	case model.CompareTokens:
+		ok, message, err = sameTokens(bytes.NewReader(output), f, equalToken)
+	case model.CompareTokensCI:
+		ok, message, err = sameTokens(bytes.NewReader(output), f, strings.EqualFold)
+	case model.CompareFloat:
+		ok, message, err = sameTokens(bytes.NewReader(output), f, equalFloat(problem.Epsilon))
+	default:
+		return model.SystemError, "", fmt.Errorf("unknown comparison mode %s", problem.Compare)
+	}
+	if err != nil {
+		return model.SystemError, "", err
+	}
+	if ok {
+		return model.Accept, message, nil
+	}
+	if problem.Compare == model.CompareTokens || problem.Compare == model.CompareTokensCI || problem.Compare == model.CompareFloat {
+		return model.WrongAnswer, message, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return model.SystemError, "", err
+	}
+	ok, message, err = sameTokens(bytes.NewReader(output), f, equalToken)
+	if err != nil {
+		return model.SystemError, "", err
+	}
+	if ok {
+		return model.PresentationError, "output differs from answer in whitespace", nil
+	}
+	return model.WrongAnswer, message, nil
+}
+
+// sameBytes reports whether a and b have the same bytes.
+func sameBytes(a, b io.Reader) (bool, error) {
+	ra, rb := bufio.NewReader(a), bufio.NewReader(b)
+	for {
+		ca, errA := ra.ReadByte()
+		cb, errB := rb.ReadByte()
+		if errA == io.EOF && errB == io.EOF {
+			return true, nil
+		}
+		if errA != nil && errA != io.EOF {
+			return false, errA
+		}
+		if errB != nil && errB != io.EOF {
+			return false, errB
+		}
+		if errA != nil || errB != nil || ca != cb {
+			return false, nil
+		}
+	}
+}
+
+// sameLines reports whether a and b have the same lines ignoring trailing
+// whitespace of lines and trailing empty lines.
+func sameLines(a, b io.Reader) (bool, string, error) {
+	ra, rb := bufio.NewReader(a), bufio.NewReader(b)
+	for nth := 1; ; nth++ {
+		la, errA := ra.ReadString('\n')
+		lb, errB := rb.ReadString('\n')
+		if errA != nil && errA != io.EOF {
+			return false, "", errA
+		}
+		if errB != nil && errB != io.EOF {
+			return false, "", errB
+		}
+		la, lb = strings.TrimRight(la, " \t\r\n\f\v"), strings.TrimRight(lb, " \t\r\n\f\v")
+		if la != lb {
+			return false, fmt.Sprintf("line %d differs", nth), nil
+		}
+		if errA == io.EOF && errB == io.EOF {
+			return true, "", nil
+		}
+	}
+}
+
+// sameTokens reports whether a and b have the same whitespace separated
+// tokens by equal.
+func sameTokens(a, b io.Reader, equal func(output, answer string) bool) (bool, string, error) {
+	sa, sb := tokenScanner(a), tokenScanner(b)
+	for nth := 1; ; nth++ {
+		okA, okB := sa.Scan(), sb.Scan()
+		if !okA && sa.Err() != nil {
+			return false, "", sa.Err()
+		}
+		if !okB && sb.Err() != nil {
+			return false, "", sb.Err()
+		}
+		switch {
+		case !okA && !okB:
+			return true, fmt.Sprintf("%d tokens", nth-1), nil
+		case !okA:
+			return false, fmt.Sprintf("expected token %d %s, but output ends", nth, quote(sb.Text())), nil
+		case !okB:
+			return false, fmt.Sprintf("extra token %d %s", nth, quote(sa.Text())), nil
+		}
+		if !equal(sa.Text(), sb.Text()) {
+			return false, fmt.Sprintf("token %d differs, expected %s, found %s", nth, quote(sb.Text()), quote(sa.Text())), nil
+		}
+	}
+}
+
+// maxToken is the limit of the length of a token in byte.
+const maxToken = 1 << 20
+
+func tokenScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 4096), maxToken)
+	s.Split(bufio.ScanWords)
+	return s
+}
+
+// quote quotes a token shortened for messages.
+func quote(token string) string {
+	if len(token) > 64 {
+		token = token[:64] + "..."
+	}
+	return strconv.Quote(token)
+}
+
+func equalToken(output, answer string) bool {
+	return output == answer
+}
+
+// equalFloat returns an equal of tokens where numbers are equal within
+// absolute or relative error eps.
+func equalFloat(eps float64) func(output, answer string) bool {
+	return func(output, answer string) bool {
+		x, errX := strconv.ParseFloat(output, 64)
+		y, errY := strconv.ParseFloat(answer, 64)
+		if errX != nil || errY != nil {
+			return output == answer
+		}
+		diff := math.Abs(x - y)
+		return diff <= eps || diff <= eps*math.Abs(y)
+	}
+}
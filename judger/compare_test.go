@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+func TestCompare(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	answer := filepath.Join(dir, "answer")
+	for _, c := range []struct {
+		compare string
+		eps     float64
+		output  string
+		answer  string
+		status  model.JudgeResult
+	}{
+		{model.CompareExact, 0, "1 2\n3\n", "1 2\n3\n", model.Accept},
+		{"", 0, "1 2\n3\n", "1 2\n3\n", model.Accept},
+		{model.CompareExact, 0, "1 2 \n3", "1 2\n3\n", model.PresentationError},
+		{model.CompareExact, 0, "1 2\n4\n", "1 2\n3\n", model.WrongAnswer},
+		{model.CompareTrailing, 0, "1 2  \r\n3\n\n\n", "1 2\n3\n", model.Accept},
+		{model.CompareTrailing, 0, "1\n2\n3\n", "1 2\n3\n", model.PresentationError},
+		{model.CompareTrailing, 0, "1 2\n", "1 2\n3\n", model.WrongAnswer},
+		{model.CompareTokens, 0, "1\n2\n3", "1 2\n3\n", model.Accept},
+		{model.CompareTokens, 0, "1 2", "1 2\n3\n", model.WrongAnswer},
+		{model.CompareTokens, 0, "1 2 3 4", "1 2\n3\n", model.WrongAnswer},
+		{model.CompareTokens, 0, "yes", "YES", model.WrongAnswer},
+		{model.CompareTokensCI, 0, "yes", "YES", model.Accept},
+		{model.CompareFloat, 1e-6, "0.3333333 x", "0.33333333 x", model.Accept},
+		{model.CompareFloat, 1e-6, "1000000.5", "1000000", model.Accept},
+		{model.CompareFloat, 1e-6, "0.334", "0.333", model.WrongAnswer},
+		{model.CompareFloat, 1e-6, "x", "y", model.WrongAnswer},
+	} {
+		if err := ioutil.WriteFile(answer, []byte(c.answer), 0644); err != nil {
+			t.Fatal(err)
+		}
+		status, message, err := compare(model.Problem{Compare: c.compare, Epsilon: c.eps}, []byte(c.output), answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != c.status {
+			t.Errorf("compare %s %q with %q: get %v (%s), want %v", c.compare, c.output, c.answer, status, message, c.status)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/jury"
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/libsandbox"
+)
+
+// transcriptLimit is the limit of the size of a transcript kept in byte.
+var transcriptLimit = 64 << 10
+
+// transcript records what a program and an interactor say to each other,
+// each line is prefixed by who says it.
+type transcript struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+}
+
+// writer returns a writer of what is said with prefix, it never fails so
+// the conversation goes on when the transcript is full.
+func (t *transcript) writer(prefix string) io.Writer {
+	return &transcriptWriter{t: t, prefix: prefix, start: true}
+}
+
+func (t *transcript) write(p []byte) {
+	if left := transcriptLimit - t.buf.Len(); len(p) > left {
+		p = p[:left]
+		t.truncated = true
+	}
+	t.buf.Write(p)
+}
+
+// Bytes returns the transcript.
+func (t *transcript) Bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := append([]byte(nil), t.buf.Bytes()...)
+	if t.truncated {
+		b = append(b, "\n... truncated\n"...)
+	}
+	return b
+}
+
+type transcriptWriter struct {
+	t      *transcript
+	prefix string
+	start  bool // at the start of a line
+}
+
+func (w *transcriptWriter) Write(p []byte) (int, error) {
+	w.t.mu.Lock()
+	defer w.t.mu.Unlock()
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if w.start {
+			w.t.write([]byte(w.prefix))
+		}
+		w.t.write(line)
+		w.start = line[len(line)-1] == '\n'
+	}
+	return len(p), nil
+}
+
+var (
+	// programFileLimit is the limit of the size of a file the program of
+	// an interactive problem writes
+	programFileLimit = int64(16 << 20)
+	// programProcs is the limit of processes and threads of the dedicated
+	// user of confine, which runs programs of interactive problems
+	programProcs = 64
+)
+
+// compileProgram compiles source of code to binary by the sandbox like the
+// program of a standard problem, a compile error is the status of the
+// result. The sandbox compiles and runs at once, so the program is run on
+// an empty input and its verdict is dropped.
+func compileProgram(code model.Code, problem model.Problem, source, binary string) (Result, error) {
+	empty := binary + ".empty"
+	if err := ioutil.WriteFile(empty, nil, 0644); err != nil {
+		return Result{}, err
+	}
+	defer os.Remove(empty)
+	rslt, err := runSandbox(sandboxArgs(code, problem, false, source, binary, empty, empty))
+	if err != nil {
+		return Result{}, err
+	}
+	if rslt.Status == model.CompileError {
+		return rslt, nil
+	}
+	return Result{Status: model.Accept}, nil
+}
+
+// stage links binary into a new directory of its own and returns the path
+// of the link, so the program run by the dedicated user of confine reaches
+// no tests. The directory is removed by the caller.
+func stage(binary string) (string, error) {
+	dir, err := confine.Contestant.TempDir("program-")
+	if err != nil {
+		return "", err
+	}
+	program := filepath.Join(dir, "main")
+	err = link(binary, program)
+	if err == nil {
+		err = os.Chmod(program, 0755)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return program, nil
+}
+
+// programLimits are the limits of the program of an interactive problem
+// set by confine, they are above the limits watched so exceeding a limit
+// of problem is a verdict of watch.
+func programLimits(problem model.Problem) confine.Limits {
+	return confine.Limits{
+		CPU: 2 * time.Duration(problem.TimeLimit) * time.Millisecond,
+		// like the limit of virtual memory of the sandbox
+		Memory: 10 * problem.MemoryLimit,
+		File:   programFileLimit,
+		Procs:  programProcs,
+	}
+}
+
+// interact runs binary confined and interactor on input, what binary writes
+// is read by interactor and vice versa, both are written to t. The limits of
+// problem are enforced on binary only, the interactor decides the verdict
+// and the score like a testlib checker unless binary fails itself.
+func interact(problem model.Problem, binary, interactor, input, answer string, t *transcript) (Result, float64, error) {
+	output := answer + ".tout"
+	defer os.Remove(output)
+	// the interactor may wait for the program until it is killed
+	timeout := time.Duration(3*problem.TimeLimit/2)*time.Millisecond + checkerTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	program, err := confine.Contestant.Command(context.Background(), programLimits(problem), binary)
+	if err != nil {
+		return Result{}, 0, err
+	}
+	juryCmd, err := jury.Command(ctx, juryLimits(timeout), interactor, input, output, answer)
+	if err != nil {
+		return Result{}, 0, err
+	}
+	var stderr jury.Message
+	juryCmd.Stderr = &stderr
+	// the program has pipes of its own, so it is waited for as soon as it
+	// exits and stops being watched
+	programIn, juryToProgram, err := os.Pipe()
+	if err != nil {
+		return Result{}, 0, err
+	}
+	defer juryToProgram.Close()
+	programToJury, programOut, err := os.Pipe()
+	if err != nil {
+		programIn.Close()
+		return Result{}, 0, err
+	}
+	defer programToJury.Close()
+	program.Stdin, program.Stdout = programIn, programOut
+	juryIn, err := juryCmd.StdinPipe()
+	if err != nil {
+		return Result{}, 0, err
+	}
+	juryOut, err := juryCmd.StdoutPipe()
+	if err != nil {
+		return Result{}, 0, err
+	}
+	if err := juryCmd.Start(); err != nil {
+		return Result{}, 0, fmt.Errorf("run interactor: %v", err)
+	}
+	err = program.Start()
+	programIn.Close()
+	programOut.Close()
+	if err != nil {
+		juryCmd.Process.Kill()
+		juryCmd.Wait()
+		return Result{}, 0, err
+	}
+
+	done := make(chan struct{})
+	exceeded := make(chan model.JudgeResult, 1)
+	go func() {
+		exceeded <- watch(program.Process, problem, done)
+	}()
+	programExit := make(chan error, 1)
+	go func() {
+		err := program.Wait()
+		close(done)
+		programExit <- err
+	}()
+	programSaid := relay(juryIn, t.writer("> "), programToJury)
+	jurySaid := relay(juryToProgram, t.writer("< "), juryOut)
+
+	<-jurySaid
+	juryErr := juryCmd.Wait()
+	code := 0
+	if juryErr != nil {
+		exitErr, ok := juryErr.(*exec.ExitError)
+		if !ok || ctx.Err() != nil {
+			code = -1
+		} else {
+			code = exitErr.ExitCode()
+		}
+	}
+	message := stderr.String()
+	status, points, verdictErr := verdictOf(code, message)
+	killed := false
+	if status != model.Accept {
+		// the verdict is given, the program can not do better, killing
+		// an exited program fails
+		killed = program.Process.Kill() == nil
+	}
+	programErr := <-programExit
+	<-programSaid
+
+	rslt := Result{Status: status, Error: message}
+	if usage, ok := program.ProcessState.SysUsage().(*syscall.Rusage); ok {
+		rslt.Time = (program.ProcessState.UserTime() + program.ProcessState.SystemTime()).Nanoseconds() / int64(time.Millisecond)
+		rslt.Memory = usage.Maxrss
+	}
+	switch limit := <-exceeded; {
+	case limit != model.Unhandled:
+		rslt.Status, points = limit, 0
+	case verdictErr != nil:
+		return rslt, 0, fmt.Errorf("interactor: %v", verdictErr)
+	case programErr != nil && !(killed && killedBy(program.ProcessState, syscall.SIGKILL)):
+		rslt.Status, rslt.Error, points = model.RuntimeError, programErr.Error(), 0
+	}
+	return rslt, points, nil
+}
+
+// killedBy reports whether the process of state ended by signal sig.
+func killedBy(state *os.ProcessState, sig syscall.Signal) bool {
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	return ok && ws.Signaled() && ws.Signal() == sig
+}
+
+// relay copies what r says to w and t until r is closed, then closes w. The
+// rest is discarded if w is closed first, so the writer of r never blocks.
+func relay(w io.WriteCloser, t io.Writer, r io.Reader) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := io.Copy(io.MultiWriter(w, t), r); err != nil {
+			io.Copy(ioutil.Discard, r)
+		}
+		w.Close()
+	}()
+	return done
+}
+
+// watch kills p once it exceeds the limits of problem like the sandbox and
+// returns the verdict, or Unhandled if p ends in limits before done is
+// closed.
+func watch(p *os.Process, problem model.Problem, done <-chan struct{}) model.JudgeResult {
+	ticker := time.NewTicker(libsandbox.TICK)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return model.Unhandled
+		case <-ticker.C:
+		}
+		ok, _, rss, runningTime, cpuTime := libsandbox.GetResourceUsage(p.Pid)
+		if !ok {
+			return model.Unhandled
+		}
+		// a program waiting for the interactor uses no cpu, so running time
+		// is limited too
+		if cpuTime > problem.TimeLimit || runningTime > 3*problem.TimeLimit/2 {
+			p.Kill()
+			return model.TimeLimitExceeded
+		}
+		if rss*3 > problem.MemoryLimit*2 {
+			p.Kill()
+			return model.MemoryLimitExceeded
+		}
+	}
+}
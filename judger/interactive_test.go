@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+func TestTranscript(t *testing.T) {
+	tr := new(transcript)
+	program, jury := tr.writer("> "), tr.writer("< ")
+	jury.Write([]byte("41\n"))
+	program.Write([]byte("4"))
+	program.Write([]byte("2\n"))
+	if got, want := string(tr.Bytes()), "< 41\n> 42\n"; got != want {
+		t.Errorf("get %q, want %q", got, want)
+	}
+	defer func(limit int) { transcriptLimit = limit }(transcriptLimit)
+	transcriptLimit = 4
+	tr = new(transcript)
+	tr.writer("> ").Write([]byte("hello\n"))
+	if got := string(tr.Bytes()); !strings.HasPrefix(got, "> he") || !strings.HasSuffix(got, "truncated\n") {
+		t.Errorf("get %q, want a truncated transcript", got)
+	}
+}
+
+func TestInteract(t *testing.T) {
+	dir, err := confine.Jury.TempDir("interact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	input := write("input", "41\n", 0644)
+	answer := write("answer", "", 0644)
+	// the interactor tells the number in input and wants the next one
+	interactor := write("interactor", "#!/bin/sh\ncat $1\nread y\nif [ \"$y\" = 42 ]; then echo ok >&2; exit 0; fi\necho wrong >&2; exit 1\n", 0755)
+	problem := model.Problem{TimeLimit: 1000, MemoryLimit: 256 << 20}
+	for _, c := range []struct {
+		program string
+		status  model.JudgeResult
+	}{
+		{"#!/bin/sh\nread x\necho $((x+1))\n", model.Accept},
+		{"#!/bin/sh\nread x\necho $x\n", model.WrongAnswer},
+		{"#!/bin/sh\nread x\nexit 3\n", model.RuntimeError},
+		{"#!/bin/sh\nread x\nwhile :; do :; done\n", model.TimeLimitExceeded},
+	} {
+		binary := write("program", c.program, 0755)
+		// the program runs alone like in judges
+		program, err := stage(binary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := new(transcript)
+		rslt, _, err := interact(problem, program, interactor, input, answer, tr)
+		os.RemoveAll(filepath.Dir(program))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rslt.Status != c.status {
+			t.Errorf("%q: get %v (%s), want %v", c.program, rslt.Status, rslt.Error, c.status)
+		}
+		if !strings.HasPrefix(string(tr.Bytes()), "< 41\n") {
+			t.Errorf("%q: transcript %q", c.program, tr.Bytes())
+		}
+		os.Remove(binary)
+	}
+}
+
+func TestStage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	binary := filepath.Join(dir, "main")
+	if err := ioutil.WriteFile(binary, []byte("#!/bin/sh\necho ok\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	program, err := stage(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(program))
+	if filepath.Dir(program) == dir {
+		t.Fatal("the program is staged beside the tests")
+	}
+	if out, err := exec.Command(program).Output(); err != nil || string(out) != "ok\n" {
+		t.Errorf("run staged program: %q, %v", out, err)
+	}
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,12 +19,16 @@ import (
 	"github.com/go-xorm/xorm"
 	_ "github.com/lib/pq"
 
+	"github.com/ggaaooppeenngg/OJ/confine"
 	"github.com/ggaaooppeenngg/OJ/loghook"
 	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
 )
 
 var (
 	engine *xorm.Engine
+	store  storage.Storage
+	cache  *Cache
 )
 
 type M log.Fields
@@ -47,11 +58,16 @@ func getUnhandledCode() <-chan model.Code {
 				log.Error(err)
 			}
 			for _, code := range codes {
-				_, err = engine.Id(code.Id).Cols("status").Update(&model.Code{Status: model.Handling, Version: code.Version})
+				// the version check makes sure only one judger claims the code
+				affected, err := engine.Id(code.Id).Cols("status").Update(&model.Code{Status: model.Handling, Version: code.Version})
 				if err != nil {
 					log.Error(err)
 					continue
 				}
+				if affected == 0 {
+					continue
+				}
+				code.Version++
 				log.Debug("Update set handling")
 				unHandledCodeChan <- code
 			}
@@ -80,104 +96,551 @@ func (r *Result) Init() {
 	}
 }
 
-func judgeCode(codeChan <-chan model.Code) {
-	for codec := range codeChan {
-		// TODO: taskpool
-		code := codec
-		go func() {
-			var problem model.Problem
-			if _, err := engine.Id(code.ProblemId).Get(&problem); err != nil {
-				log.Error(err)
-				_, err = engine.Id(code.Id).Cols("status").Update(&model.Code{Status: model.RuntimeError, Version: code.Version})
-				if err != nil {
-					log.Error(err)
-				}
-				return
-			}
-			cmd := exec.Command("sandbox",
-				fmt.Sprintf("--lang=%s", strings.ToLower(code.Language.String())),
-				fmt.Sprintf("--time=%d", problem.TimeLimit),
-				fmt.Sprintf("--memory=%d", problem.MemoryLimit),
-				"--compile",
-				"--source", code.SourcePath(),
-				"--binary", code.BinaryPath(),
-				"--input", problem.InputTestPath(),
-				"--output", problem.OutputTestPath(),
-			)
-			out, err := cmd.CombinedOutput()
+// fetch downloads key from the storage to the local file dst, the content is
+// verified against digest unless digest is empty.
+func fetch(key, digest, dst string) error {
+	r, err := store.Get(key)
+	if err != nil {
+		return fmt.Errorf("get %s: %v", key, err)
+	}
+	defer r.Close()
+	var src io.Reader = r
+	if digest != "" {
+		src = storage.NewVerifier(r, digest)
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		os.Remove(dst)
+		return fmt.Errorf("get %s: %v", key, err)
+	}
+	return f.Close()
+}
+
+// fetchCached is fetch going through the cache, files without digest may
+// change in place and are not cached.
+func fetchCached(key, digest, dst string) error {
+	if digest == "" {
+		return fetch(key, digest, dst)
+	}
+	return cache.Link("sha256-"+digest, dst, func(path string) error {
+		return fetch(key, digest, path)
+	})
+}
+
+// binaryKey is the cache key of the compiled binary of code.
+func binaryKey(code model.Code) string {
+	return fmt.Sprintf("bin-%s-%s", strings.ToLower(code.Language.String()), code.SourceHash)
+}
+
+// warm fetches the tests of problem id into the cache.
+func warm(id int64) error {
+	problem, cases, err := revisionOf(id)
+	if err != nil {
+		return err
+	}
+	tests, err := testsOf(problem, cases)
+	if err != nil {
+		return err
+	}
+	for _, t := range tests {
+		if err := fetchCached(t.input, t.inputDigest, ""); err != nil {
+			return err
+		}
+		if err := fetchCached(t.output, t.outputDigest, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// test is a test case to judge, the single test of problems created before
+// test cases may not be content addressed.
+type test struct {
+	nth          int
+	input        string
+	inputDigest  string
+	output       string
+	outputDigest string
+}
+
+// revisionOf returns problem id at its current revision with its test cases
+// and subtasks, problems without revisions are read as they are.
+func revisionOf(id int64) (model.Problem, []model.TestCase, error) {
+	var problem model.Problem
+	has, err := engine.Id(id).Get(&problem)
+	if err != nil {
+		return problem, nil, err
+	}
+	if !has {
+		return problem, nil, fmt.Errorf("problem %d not found", id)
+	}
+	if problem.Revision == 0 {
+		var cases []model.TestCase
+		if err := engine.Where("problem_id = ?", id).Asc("nth").Find(&cases); err != nil {
+			return problem, nil, err
+		}
+		err := engine.Where("problem_id = ?", id).Asc("nth").Find(&problem.Subtasks)
+		return problem, cases, err
+	}
+	var revision model.ProblemRevision
+	has, err = engine.Where("problem_id = ? AND revision = ?", id, problem.Revision).Get(&revision)
+	if err != nil {
+		return problem, nil, err
+	}
+	if !has {
+		return problem, nil, fmt.Errorf("revision %d of problem %d not found", problem.Revision, id)
+	}
+	return revision.Problem(), revision.Cases, nil
+}
+
+// testsOf returns the tests of problem with cases in order.
+func testsOf(problem model.Problem, cases []model.TestCase) ([]test, error) {
+	if len(cases) == 0 {
+		return []test{{1, problem.InputTestPath(), problem.InputHash, problem.OutputTestPath(), problem.OutputHash}}, nil
+	}
+	var tests []test
+	for _, t := range cases {
+		if t.InputHash == "" || t.OutputHash == "" {
+			return nil, fmt.Errorf("test case %d of problem %d is incomplete", t.Nth, problem.Id)
+		}
+		tests = append(tests, test{t.Nth, t.InputPath(), t.InputHash, t.OutputPath(), t.OutputHash})
+	}
+	return tests, nil
+}
+
+// setStatus sets the status of a code which can not be judged.
+func setStatus(code model.Code, status model.JudgeResult) {
+	_, err := engine.Id(code.Id).Cols("status").Update(&model.Code{Status: status, Version: code.Version})
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// runSandbox runs the sandbox with args and parses its result.
+func runSandbox(args []string) (Result, error) {
+	cmd := exec.Command("sandbox", args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"command": strings.Join(cmd.Args, " "),
+			"output":  string(out),
+		}).Error(err)
+		return Result{}, err
+	}
+	var rslt Result
+	if err := json.Unmarshal(out, &rslt); err != nil {
+		log.WithFields(log.Fields{"command": strings.Join(cmd.Args, " ")}).
+			Errorf("exception out %s", out)
+		return Result{Status: model.PanicError, PanicOutput: string(out)}, err
+	}
+	rslt.Init()
+	return rslt, nil
+}
+
+// judge runs code on every test case of its problem.
+func judge(code model.Code) {
+	// the problem is judged at its current revision, which never changes
+	problem, testCases, err := revisionOf(code.ProblemId)
+	if err != nil {
+		log.WithFields(log.Fields{"code": code.Id}).Error(err)
+		setStatus(code, model.SystemError)
+		return
+	}
+	code.Revision = problem.Revision
+	subtasks := problem.Subtasks
+	tests, err := testsOf(problem, testCases)
+	if err != nil {
+		log.WithFields(log.Fields{"code": code.Id}).Error(err)
+		setStatus(code, model.SystemError)
+		return
+	}
+	// checkers and interactors read the tests as the jury user, which
+	// contestants must not
+	dir, err := confine.Jury.TempDir(fmt.Sprintf("code-%d-", code.Id))
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	var (
+		source = filepath.Join(dir, "main."+strings.ToLower(code.Language.String()))
+		binary = filepath.Join(dir, "main")
+	)
+	// output files of an output-only problem need no compiling
+	compiled := problem.Type == model.OutputOnly
+	if !compiled {
+		if err := fetchCached(code.SourcePath(), code.SourceHash, source); err != nil {
+			log.WithFields(log.Fields{"code": code.Id}).Error(err)
+			setStatus(code, model.SystemError)
+			return
+		}
+		compiled = code.SourceHash != "" && cache.Lookup(binaryKey(code), binary)
+	}
+	var checker, interactor, program string
+	if problem.CheckerHash != "" {
+		checker = filepath.Join(dir, "checker")
+		if err := compileJury(problem.CheckerPath(), problem.CheckerHash, checker); err != nil {
+			log.WithFields(log.Fields{"code": code.Id}).Error(err)
+			setStatus(code, model.SystemError)
+			return
+		}
+	}
+	if problem.Type == model.Interactive {
+		interactor = filepath.Join(dir, "interactor")
+		if problem.InteractorHash == "" {
+			log.WithFields(log.Fields{"code": code.Id}).Errorf("problem %d has no interactor", problem.Id)
+			setStatus(code, model.SystemError)
+			return
+		}
+		if err := compileJury(problem.InteractorPath(), problem.InteractorHash, interactor); err != nil {
+			log.WithFields(log.Fields{"code": code.Id}).Error(err)
+			setStatus(code, model.SystemError)
+			return
+		}
+		// the sandbox compiles and runs at once, but an interactive
+		// program is run by the judger
+		if !compiled {
+			rslt, err := compileProgram(code, problem, source, binary)
 			if err != nil {
-				log.WithFields(log.Fields{
-					"command": strings.Join(cmd.Args, " "),
-					"output":  string(out),
-				}).Error(err)
-				_, err = engine.Id(code.Id).Cols("status").Update(&model.Code{Status: model.RuntimeError, Version: code.Version})
-				if err != nil {
-					log.Error(err)
-				}
+				log.WithFields(log.Fields{"code": code.Id}).Error(err)
+				setStatus(code, model.SystemError)
 				return
 			}
-			var rslt Result
-			if err := json.Unmarshal(out, &rslt); err != nil {
-				rslt = Result{Status: model.PanicError, PanicOutput: fmt.Sprintf("%s", out)}
-				log.WithFields(log.Fields{"code": code}).
-					Errorf("exception out %s", out)
-				_, err = engine.Id(code.Id).Cols("status").Update(&model.Code{Status: model.RuntimeError, Version: code.Version})
-				if err != nil {
-					log.Error(err)
-				}
+			if rslt.Status == model.CompileError {
+				code.Score, code.MaxScore = score(subtasks, tests, nil)
+				saveResult(code, rslt, nil)
 				return
 			}
-			rslt.Init()
-			transaction := engine.NewSession()
-			defer transaction.Close()
-			err = transaction.Begin()
-			if err != nil {
-				log.Error(err)
+		}
+		if program, err = stage(binary); err != nil {
+			log.WithFields(log.Fields{"code": code.Id}).Error(err)
+			setStatus(code, model.SystemError)
+			return
+		}
+		defer os.RemoveAll(filepath.Dir(program))
+	}
+
+	final := Result{Status: model.Accept}
+	var cases []model.CodeCaseResult
+	for _, t := range tests {
+		input := filepath.Join(dir, fmt.Sprintf("%d.in", t.nth))
+		output := filepath.Join(dir, fmt.Sprintf("%d.out", t.nth))
+		for _, f := range []struct{ key, digest, dst string }{
+			{t.input, t.inputDigest, input},
+			{t.output, t.outputDigest, output},
+		} {
+			if err := fetchCached(f.key, f.digest, f.dst); err != nil {
+				log.WithFields(log.Fields{"code": code.Id, "test": t.nth}).Error(err)
+				setStatus(code, model.SystemError)
 				return
 			}
-			if _, err := transaction.Id(code.Id).Cols("status", "time", "memory", "nth", "wrong_answer").Update(model.Code{
-				Status:      rslt.Status,
-				Time:        rslt.Time,
-				Memory:      rslt.Memory,
-				Nth:         rslt.Nth,
-				WrongAnswer: rslt.WrongAnswer,
-				Version:     code.Version,
-			}); err != nil {
-				log.Error(err)
-				err = transaction.Rollback()
-				if err != nil {
+		}
+		var (
+			rslt       Result
+			points     float64
+			transcript string
+			err        error
+		)
+		switch {
+		case problem.Type == model.OutputOnly:
+			rslt, points, err = runOutput(code, problem, t.nth, checker, input, output)
+		case interactor != "":
+			rslt, points, transcript, err = runInteractive(code, problem, t.nth, program, interactor, input, output)
+		default:
+			rslt, points, err = runStandard(code, problem, compiled, checker, source, binary, input, output)
+		}
+		os.Remove(input)
+		os.Remove(output)
+		if err != nil {
+			log.WithFields(log.Fields{"code": code.Id, "test": t.nth}).Error(err)
+			setStatus(code, rslt.Status)
+			return
+		}
+		if rslt.Status == model.CompileError {
+			final = rslt
+			cases = nil
+			break
+		}
+		if !compiled {
+			compiled = true
+			if code.SourceHash != "" {
+				if err := cache.Put(binaryKey(code), binary); err != nil {
 					log.Error(err)
 				}
-				return
 			}
-			if _, err := engine.Id(code.ProblemId).Incr("solved", 1).Update(model.Problem{}); err != nil {
-				log.Error(err)
-				err = transaction.Rollback()
-				if err != nil {
-					log.Error(err)
-				}
+		}
+		if rslt.Status == model.Accept {
+			rslt.WrongAnswer = ""
+		}
+		cases = append(cases, model.CodeCaseResult{
+			CodeId:     code.Id,
+			Nth:        t.nth,
+			Status:     rslt.Status,
+			Time:       rslt.Time,
+			Memory:     rslt.Memory,
+			Score:      points,
+			Message:    rslt.Error,
+			Transcript: transcript,
+		})
+		if rslt.Time > final.Time {
+			final.Time = rslt.Time
+		}
+		if rslt.Memory > final.Memory {
+			final.Memory = rslt.Memory
+		}
+		// the first failed test decides the verdict
+		if final.Status == model.Accept && rslt.Status != model.Accept {
+			final.Status = rslt.Status
+			final.Nth = t.nth
+			final.WrongAnswer = rslt.WrongAnswer
+		}
+	}
+	code.Score, code.MaxScore = score(subtasks, tests, cases)
+	saveResult(code, final, cases)
+}
+
+// runStandard runs the program of code on input in the sandbox, compiling it
+// first unless compiled, and judges its output against answer. On errors the
+// status of the code is in the result.
+func runStandard(code model.Code, problem model.Problem, compiled bool, checker, source, binary, input, answer string) (Result, float64, error) {
+	rslt, err := runSandbox(sandboxArgs(code, problem, compiled, source, binary, input, answer))
+	if err != nil {
+		return Result{Status: model.RuntimeError}, 0, err
+	}
+	points := caseScore(rslt)
+	if checker != "" && finished(rslt.Status) {
+		if rslt.Status, points, rslt.Error, err = runChecker(checker, rslt, input, answer); err != nil {
+			return Result{Status: model.SystemError}, 0, err
+		}
+	} else if rslt.Status == model.WrongAnswer || rslt.Status == model.PresentationError {
+		// an accepted output is the answer in any comparison mode
+		if rslt.Status, rslt.Error, err = compare(problem, []byte(rslt.Output), answer); err != nil {
+			return Result{Status: model.SystemError}, 0, err
+		}
+		points = caseScore(rslt)
+	}
+	return rslt, points, nil
+}
+
+// sandboxArgs returns the arguments of the sandbox running the program of
+// code on input, compiling source to binary first unless compiled.
+func sandboxArgs(code model.Code, problem model.Problem, compiled bool, source, binary, input, answer string) []string {
+	args := []string{
+		fmt.Sprintf("--lang=%s", strings.ToLower(code.Language.String())),
+		fmt.Sprintf("--time=%d", problem.TimeLimit),
+		fmt.Sprintf("--memory=%d", problem.MemoryLimit),
+		"--source", source,
+		"--binary", binary,
+		"--input", input,
+		"--output", answer,
+	}
+	if !compiled {
+		args = append(args, "--compile")
+	}
+	return args
+}
+
+// runOutput judges the output file of code for the nth test case of an
+// output-only problem against answer.
+func runOutput(code model.Code, problem model.Problem, nth int, checker, input, answer string) (Result, float64, error) {
+	digest, ok := code.OutputHashes[nth]
+	if !ok {
+		return Result{Status: model.WrongAnswer, Error: "no output file"}, 0, nil
+	}
+	output := answer + ".user"
+	defer os.Remove(output)
+	if err := fetchCached(code.OutputPath(nth), digest, output); err != nil {
+		return Result{Status: model.SystemError}, 0, err
+	}
+	if checker != "" {
+		status, points, message, err := check(checker, input, output, answer)
+		if err != nil {
+			return Result{Status: model.SystemError}, 0, err
+		}
+		return Result{Status: status, Error: message}, points, nil
+	}
+	b, err := ioutil.ReadFile(output)
+	if err != nil {
+		return Result{Status: model.SystemError}, 0, err
+	}
+	status, message, err := compare(problem, b, answer)
+	if err != nil {
+		return Result{Status: model.SystemError}, 0, err
+	}
+	rslt := Result{Status: status, Error: message}
+	return rslt, caseScore(rslt), nil
+}
+
+// runInteractive runs the program of code with interactor on the input of
+// the nth test case, the transcript is saved to the storage and its key
+// returned.
+func runInteractive(code model.Code, problem model.Problem, nth int, binary, interactor, input, answer string) (Result, float64, string, error) {
+	t := new(transcript)
+	rslt, points, err := interact(problem, binary, interactor, input, answer, t)
+	if err != nil {
+		return Result{Status: model.SystemError}, 0, "", err
+	}
+	key := fmt.Sprintf("transcripts/%d/%d", code.Id, nth)
+	b := t.Bytes()
+	if err := store.Put(key, bytes.NewReader(b), int64(len(b))); err != nil {
+		// the verdict is kept without the transcript
+		log.WithFields(log.Fields{"code": code.Id, "test": nth}).Error(err)
+		key = ""
+	}
+	return rslt, points, key, nil
+}
+
+// finished reports whether the program finished in limits with status, so
+// its output can be checked.
+func finished(status model.JudgeResult) bool {
+	return status == model.Accept || status == model.WrongAnswer || status == model.PresentationError
+}
+
+// runChecker checks the output of the program in rslt on input with checker
+// against answer.
+func runChecker(checker string, rslt Result, input, answer string) (model.JudgeResult, float64, string, error) {
+	output := answer + ".user"
+	defer os.Remove(output)
+	if rslt.Status == model.Accept {
+		// an accepted output is the answer
+		if err := link(answer, output); err != nil {
+			return model.SystemError, 0, "", err
+		}
+	} else if err := ioutil.WriteFile(output, []byte(rslt.Output), 0644); err != nil {
+		return model.SystemError, 0, "", err
+	}
+	return check(checker, input, output, answer)
+}
+
+// caseScore returns the score in [0, 1] of a result on a test case.
+func caseScore(rslt Result) float64 {
+	if rslt.Status == model.Accept {
+		return 1
+	}
+	return 0
+}
+
+// saveResult saves the verdict of code and its results on test cases.
+func saveResult(code model.Code, rslt Result, cases []model.CodeCaseResult) {
+	transaction := engine.NewSession()
+	defer transaction.Close()
+	err := transaction.Begin()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	rollback := func(err error) {
+		log.Error(err)
+		if err := transaction.Rollback(); err != nil {
+			log.Error(err)
+		}
+	}
+	// results of an earlier judge of the same code are replaced
+	if _, err := transaction.Where("code_id = ?", code.Id).Delete(&model.CodeCaseResult{}); err != nil {
+		rollback(err)
+		return
+	}
+	for i := range cases {
+		if _, err := transaction.InsertOne(&cases[i]); err != nil {
+			rollback(err)
+			return
+		}
+	}
+	if _, err := transaction.Id(code.Id).Cols("status", "time", "memory", "nth", "wrong_answer", "score", "max_score", "revision").Update(model.Code{
+		Status:      rslt.Status,
+		Time:        rslt.Time,
+		Memory:      rslt.Memory,
+		Nth:         rslt.Nth,
+		WrongAnswer: rslt.WrongAnswer,
+		Score:       code.Score,
+		MaxScore:    code.MaxScore,
+		Revision:    code.Revision,
+		Version:     code.Version,
+	}); err != nil {
+		rollback(err)
+		return
+	}
+	// reference solutions do not solve their problem
+	if rslt.Status == model.Accept && code.SolutionId == 0 {
+		if _, err := transaction.Id(code.ProblemId).Incr("solved", 1).Update(model.Problem{}); err != nil {
+			rollback(err)
+			return
+		}
+	}
+	if err := transaction.Commit(); err != nil {
+		log.Error(err)
+	}
+}
+
+func judgeCode(codeChan <-chan model.Code) {
+	for code := range codeChan {
+		// TODO: taskpool
+		go func(code model.Code) {
+			judge(code)
+			if code.SolutionId == 0 {
 				return
 			}
-			err = transaction.Commit()
-			if err != nil {
-				log.Error(err)
-				return
+			if err := verify(code.ProblemId); err != nil {
+				log.WithFields(log.Fields{"problem": code.ProblemId}).Error(err)
 			}
-
-		}()
+		}(code)
 	}
 }
 
+var warmFlag = flag.String("warm", "", "comma separated ids of problems whose tests are cached on start")
+
 func main() {
+	flag.Parse()
 	var err error
 	engine, err = xorm.NewEngine("postgres", os.Getenv("DATABASE_URL"))
 	if err != nil {
 		panic(err)
 	}
 	engine.ShowSQL(true)
+	store, err = storage.FromEnv()
+	if err != nil {
+		panic(err)
+	}
 	log.AddHook(loghook.NewCallerHook())
 	log.SetLevel(log.DebugLevel)
 
+	cacheSize := int64(1 << 30)
+	if size := os.Getenv("JUDGER_CACHE_SIZE"); size != "" {
+		if cacheSize, err = strconv.ParseInt(size, 10, 64); err != nil {
+			panic(err)
+		}
+	}
+	cacheDir := os.Getenv("JUDGER_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = filepath.Join(os.TempDir(), "oj-cache")
+	}
+	cache, err = NewCache(cacheDir, cacheSize)
+	if err != nil {
+		panic(err)
+	}
+	if addr := os.Getenv("JUDGER_DEBUG_ADDR"); addr != "" {
+		// cache metrics are served at /debug/vars
+		go func() {
+			log.Error(http.ListenAndServe(addr, nil))
+		}()
+	}
+	for _, id := range strings.Split(*warmFlag, ",") {
+		if id == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err == nil {
+			err = warm(n)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{"problem": id}).Error(err)
+		}
+	}
+
 	codeChan := getUnhandledCode()
 	judgeCode(codeChan)
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+func TestRunOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store = storage.NewMemory()
+	if cache, err = NewCache(filepath.Join(dir, "cache"), 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	code := model.Code{OutputHashes: make(map[int]string)}
+	for nth, output := range map[int]string{1: "3\n", 2: "8\n"} {
+		digest := storage.Digest([]byte(output))
+		if err := store.Put(model.BlobPath("codes", digest), strings.NewReader(output), int64(len(output))); err != nil {
+			t.Fatal(err)
+		}
+		code.OutputHashes[nth] = digest
+	}
+	answer := filepath.Join(dir, "answer")
+	for nth, want := range map[int]model.JudgeResult{1: model.Accept, 2: model.WrongAnswer, 3: model.WrongAnswer} {
+		if err := ioutil.WriteFile(answer, []byte("3\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		rslt, _, err := runOutput(code, model.Problem{}, nth, "", "", answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rslt.Status != want {
+			t.Errorf("test case %d: get %v (%s), want %v", nth, rslt.Status, rslt.Error, want)
+		}
+	}
+}
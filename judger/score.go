@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// score returns the points scored with results on cases and the points of
+// a problem with subtasks and tests, a problem without subtasks is all or
+// nothing on all tests.
+func score(subtasks []model.Subtask, tests []test, cases []model.CodeCaseResult) (float64, float64) {
+	if len(subtasks) == 0 {
+		all := model.Subtask{Score: model.DefaultMaxScore, Rule: model.AllOrNothing}
+		for _, t := range tests {
+			all.Cases = append(all.Cases, t.nth)
+		}
+		subtasks = []model.Subtask{all}
+	}
+	scores := make(map[int]float64)
+	for _, c := range cases {
+		scores[c.Nth] = c.Score
+	}
+	var points, max float64
+	for _, s := range subtasks {
+		points += s.Grade(scores)
+		max += s.Score
+	}
+	return points, max
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+func TestScore(t *testing.T) {
+	tests := []test{{nth: 1}, {nth: 2}, {nth: 3}, {nth: 4}}
+	cases := []model.CodeCaseResult{
+		{Nth: 1, Score: 1},
+		{Nth: 2, Score: 0.5},
+		{Nth: 3, Score: 1},
+		{Nth: 4, Score: 0},
+	}
+	for _, c := range []struct {
+		subtasks []model.Subtask
+		cases    []model.CodeCaseResult
+		score    float64
+		max      float64
+	}{
+		{nil, cases, 0, model.DefaultMaxScore},
+		{nil, []model.CodeCaseResult{{Nth: 1, Score: 1}, {Nth: 2, Score: 1}, {Nth: 3, Score: 1}, {Nth: 4, Score: 1}}, model.DefaultMaxScore, model.DefaultMaxScore},
+		{nil, nil, 0, model.DefaultMaxScore},
+		{[]model.Subtask{
+			{Score: 10, Rule: model.AllOrNothing, Cases: []int{1, 3}},
+			{Score: 20, Rule: model.AllOrNothing, Cases: []int{1, 2}},
+		}, cases, 10, 30},
+		{[]model.Subtask{
+			{Score: 10, Rule: model.MinScore, Cases: []int{1, 2}},
+			{Score: 20, Rule: model.SumScore, Cases: []int{1, 2, 3, 4}},
+		}, cases, 5 + 12.5, 30},
+		// cases not judged score 0
+		{[]model.Subtask{
+			{Score: 10, Rule: model.SumScore, Cases: []int{1, 5}},
+		}, cases, 5, 10},
+	} {
+		score, max := score(c.subtasks, tests, c.cases)
+		if score != c.score || max != c.max {
+			t.Errorf("score %v of %v, want %v of %v", score, max, c.score, c.max)
+		}
+	}
+}
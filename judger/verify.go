@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// verification returns the verification of reference solutions by the
+// verdicts of their latest judges, pending until all of them are judged.
+func verification(solutions []model.Solution, statuses map[int64]model.JudgeResult) string {
+	valid := true
+	for _, s := range solutions {
+		status, ok := statuses[s.CodeId]
+		if !ok || status == model.Unhandled || status == model.Handling {
+			return model.Pending
+		}
+		if status != s.Expected {
+			valid = false
+		}
+	}
+	if valid {
+		return model.Valid
+	}
+	return model.Invalid
+}
+
+// verify checks the reference solutions of problem id after one of them is
+// judged, the problem is invalid once any of them gets a verdict other than
+// expected.
+func verify(problemId int64) error {
+	var solutions []model.Solution
+	if err := engine.Where("problem_id = ?", problemId).Find(&solutions); err != nil {
+		return err
+	}
+	statuses, err := model.SolutionStatuses(engine, solutions)
+	if err != nil {
+		return err
+	}
+	result := verification(solutions, statuses)
+	if result == model.Pending {
+		return nil
+	}
+	// a problem whose solutions are all removed meanwhile is not verified
+	_, err = engine.Id(problemId).Where("verification = ?", model.Pending).Cols("verification").Update(&model.Problem{Verification: result})
+	return err
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+func TestVerification(t *testing.T) {
+	solutions := []model.Solution{
+		{CodeId: 1, Expected: model.Accept, Main: true},
+		{CodeId: 2, Expected: model.TimeLimitExceeded},
+	}
+	for _, c := range []struct {
+		statuses map[int64]model.JudgeResult
+		want     string
+	}{
+		{map[int64]model.JudgeResult{1: model.Accept}, model.Pending},
+		{map[int64]model.JudgeResult{1: model.Accept, 2: model.Handling}, model.Pending},
+		{map[int64]model.JudgeResult{1: model.WrongAnswer, 2: model.Unhandled}, model.Pending},
+		{map[int64]model.JudgeResult{1: model.Accept, 2: model.TimeLimitExceeded}, model.Valid},
+		{map[int64]model.JudgeResult{1: model.Accept, 2: model.Accept}, model.Invalid},
+		{map[int64]model.JudgeResult{1: model.SystemError, 2: model.TimeLimitExceeded}, model.Invalid},
+	} {
+		if got := verification(solutions, c.statuses); got != c.want {
+			t.Errorf("verification of %v = %s, want %s", c.statuses, got, c.want)
+		}
+	}
+}
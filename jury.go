@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/jury"
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+var (
+	// juryDir keeps compiled jury programs by the digest of their source
+	juryDir = filepath.Join(os.TempDir(), "oj-jury")
+	// juryTimeout is the limit of time a jury program runs once
+	juryTimeout = 10 * time.Second
+)
+
+// juryMu serializes compiling, so a program is compiled once.
+var juryMu sync.Mutex
+
+// fetchBlob copies the file key in the storage to dst.
+func fetchBlob(key, dst string) error {
+	r, err := GetFile(key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// compileJury returns the path of the compiled C++ program whose source is
+// the file key with digest, programs are compiled once and kept in juryDir.
+func compileJury(key, digest string) (string, error) {
+	juryMu.Lock()
+	defer juryMu.Unlock()
+	binary := filepath.Join(juryDir, digest)
+	if _, err := os.Stat(binary); err == nil {
+		return binary, nil
+	}
+	if err := os.MkdirAll(juryDir, 0755); err != nil {
+		return "", err
+	}
+	// compiled aside and renamed, so a binary in juryDir is complete
+	out := binary + ".tmp"
+	err := jury.Compile(out, func(source string) error {
+		return fetchBlob(key, source)
+	})
+	if err != nil {
+		os.Remove(out)
+		return "", fmt.Errorf("compile %s: %v", key, err)
+	}
+	return binary, os.Rename(out, binary)
+}
+
+// compileSolution compiles source in lang to binary for generating answers,
+// the directory of binary is made by confine.Contestant.TempDir.
+func compileSolution(lang model.Language, source, binary string) error {
+	var (
+		out []byte
+		err error
+	)
+	switch lang {
+	case model.C:
+		out, err = jury.Build(confine.Contestant, "gcc", "-O2", "-o", binary, source, "-lm")
+	case model.CPP:
+		out, err = jury.Build(confine.Contestant, "g++", "-O2", "-std=c++11", "-o", binary, source, "-lm")
+	case model.Go:
+		out, err = jury.Build(confine.Contestant, "go", "build", "-o", binary, source)
+	default:
+		return fmt.Errorf("unknown language %v", lang)
+	}
+	if err != nil {
+		return fmt.Errorf("compile solution: %v: %s", err, out)
+	}
+	return nil
+}
+
+// juryError is a jury program which exits with a non-zero code, or runs
+// out of time with code -1.
+type juryError struct {
+	Code    int
+	Message string
+}
+
+func (e *juryError) Error() string {
+	if e.Code == -1 {
+		return e.Message
+	}
+	return fmt.Sprintf("exit %d: %s", e.Code, e.Message)
+}
+
+// runJury runs binary confined with args reading stdin and writing stdout
+// within juryTimeout, a file stdout is limited to dataQuota bytes. It
+// returns a *juryError with what it writes to stderr if it exits with a
+// non-zero code or runs out of time.
+func runJury(binary string, args []string, stdin io.Reader, stdout io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), juryTimeout)
+	defer cancel()
+	limits := confine.Limits{CPU: juryTimeout, Memory: jury.Memory, File: dataQuota, Procs: jury.Procs}
+	cmd, err := confine.Contestant.Command(ctx, limits, binary, args...)
+	if err != nil {
+		return err
+	}
+	var stderr jury.Message
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, &stderr
+	err = cmd.Run()
+	if ctx.Err() != nil || signaled(err, syscall.SIGXCPU) {
+		return &juryError{Code: -1, Message: fmt.Sprintf("runs longer than %v", juryTimeout)}
+	}
+	if signaled(err, syscall.SIGXFSZ) {
+		return &juryError{Code: -1, Message: fmt.Sprintf("writes more than %d bytes", dataQuota)}
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return &juryError{Code: exitErr.ExitCode(), Message: stderr.String()}
+	}
+	return err
+}
+
+// signaled reports whether the process of err, an error of running a
+// command, is killed by sig.
+func signaled(err error, sig syscall.Signal) bool {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return false
+	}
+	ws, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && ws.Signaled() && ws.Signal() == sig
+}
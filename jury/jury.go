@@ -0,0 +1,131 @@
+// Package jury compiles and runs the jury programs of problems, like
+// checkers, interactors, validators and generators, for both the server and
+// judgers. Their sources are untrusted, so compilers and programs are
+// confined, and jury programs run without environment.
+package jury
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+)
+
+var (
+	// Compiler compiles jury programs, TESTLIB_DIR is added to its include
+	// path for testlib.h
+	Compiler   = "g++"
+	TestlibDir = os.Getenv("TESTLIB_DIR")
+	// Memory is the limit of address space of a jury program
+	Memory = int64(2 << 30)
+	// Procs is the limit of processes of the dedicated user of confine
+	Procs = 64
+	// MessageLimit is the limit of messages of compilers and jury programs
+	// kept in byte
+	MessageLimit = 1024
+
+	// compileTimeout is the limit of time a compiler runs once
+	compileTimeout = time.Minute
+	compileMemory  = int64(4 << 30)
+	compileOutput  = int64(256 << 20)
+)
+
+// Compile compiles a C++ jury program to binary as the jury user of
+// confine, fetch writes its source to the path given.
+func Compile(binary string, fetch func(source string) error) error {
+	dir, err := confine.Jury.TempDir("jury-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "jury.cpp")
+	if err := fetch(source); err != nil {
+		return err
+	}
+	out := filepath.Join(dir, "jury")
+	args := []string{"-O2", "-std=c++11", "-o", out, source}
+	if TestlibDir != "" {
+		args = append(args, "-I", TestlibDir)
+	}
+	if msg, err := Build(confine.Jury, Compiler, args...); err != nil {
+		return fmt.Errorf("%v: %s", err, msg)
+	}
+	return copyFile(out, binary)
+}
+
+// copyFile copies the executable src to dst.
+func copyFile(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Build runs the compiler name with args confined as user, and returns what
+// it writes. Compilers keep the environment confine passes on, they need
+// PATH to find their tools.
+func Build(user confine.User, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), compileTimeout)
+	defer cancel()
+	limits := confine.Limits{CPU: compileTimeout, Memory: compileMemory, File: compileOutput, Procs: Procs}
+	cmd, err := user.Command(ctx, limits, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	var out Message
+	cmd.Stdout, cmd.Stderr = &out, &out
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		err = fmt.Errorf("runs longer than %v", compileTimeout)
+	}
+	return out.buf.Bytes(), err
+}
+
+// Command returns the command running the jury program binary with args in
+// limits as the jury user of confine with an empty environment, it is
+// killed once ctx is done.
+func Command(ctx context.Context, limits confine.Limits, binary string, args ...string) (*exec.Cmd, error) {
+	cmd, err := confine.Jury.Command(ctx, limits, binary, args...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Env = []string{}
+	return cmd, nil
+}
+
+// Message keeps the first MessageLimit bytes written to it and drops the
+// rest, so a program writing much is not stopped.
+type Message struct {
+	buf bytes.Buffer
+}
+
+func (m *Message) Write(p []byte) (int, error) {
+	if left := MessageLimit - m.buf.Len(); len(p) > left {
+		m.buf.Write(p[:left])
+	} else {
+		m.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// String returns the message without surrounding spaces.
+func (m *Message) String() string {
+	return strings.TrimSpace(m.buf.String())
+}
@@ -0,0 +1,67 @@
+package jury
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+)
+
+func TestCompile(t *testing.T) {
+	dir, err := confine.Jury.TempDir("jury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	binary := filepath.Join(dir, "jury")
+	source := "#include <cstdio>\nint main() { std::printf(\"ok\"); }\n"
+	err = Compile(binary, func(path string) error {
+		return ioutil.WriteFile(path, []byte(source), 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := Command(context.Background(), confine.Limits{}, binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := cmd.Output(); err != nil || string(out) != "ok" {
+		t.Errorf("run get %q, %v", out, err)
+	}
+
+	err = Compile(binary, func(path string) error {
+		return ioutil.WriteFile(path, []byte("int main() {"), 0644)
+	})
+	if err == nil {
+		t.Error("compile a broken source")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	os.Setenv("DATABASE_URL", "secret")
+	defer os.Unsetenv("DATABASE_URL")
+	cmd, err := Command(context.Background(), confine.Limits{}, "/usr/bin/env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Errorf("jury programs get the environment %q", out)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	var m Message
+	m.Write([]byte("  wrong "))
+	m.Write([]byte(strings.Repeat("x", 2*MessageLimit)))
+	if s := m.String(); !strings.HasPrefix(s, "wrong x") || len(s) > MessageLimit {
+		t.Errorf("message is %d bytes %q...", len(s), s[:10])
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/jury"
+)
+
+func TestRunJury(t *testing.T) {
+	dir, err := confine.Contestant.TempDir("jury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(quota int64) { dataQuota = quota }(dataQuota)
+	dataQuota = 1000
+	script := filepath.Join(dir, "gen")
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\nexec head -c $1 /dev/zero\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "output")
+	if err := runTo(script, []string{"1000"}, "", output); err != nil {
+		t.Fatal(err)
+	}
+	err = runTo(script, []string{"1001"}, "", output)
+	if e, ok := err.(*juryError); !ok || !strings.HasPrefix(e.Message, "writes more than") {
+		t.Errorf("write over the quota get %v", err)
+	}
+	if fi, err := os.Stat(output); err != nil || fi.Size() > dataQuota {
+		t.Errorf("output over the quota is written: %v", err)
+	}
+
+	// messages are cut even if the program is stopped
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\nhead -c 100000 /dev/zero >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = runTo(script, nil, "", output)
+	if e, ok := err.(*juryError); !ok || e.Code != 1 || len(e.Message) > jury.MessageLimit {
+		t.Errorf("get %v, want exit 1 with a message of at most %d bytes", err, jury.MessageLimit)
+	}
+}
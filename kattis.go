@@ -0,0 +1,890 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// kattisConfig is problem.yaml of a Kattis package, the fields other judges
+// write as strings, lists or maps are read as any of them.
+type kattisConfig struct {
+	Name           interface{}   `yaml:"name,omitempty"`     // a name, or names by language
+	Author         interface{}   `yaml:"author,omitempty"`   // a name or a list of names
+	Source         interface{}   `yaml:"source,omitempty"`   // a name, or a name and a URL
+	Keywords       interface{}   `yaml:"keywords,omitempty"` // separated by spaces, or a list
+	Validation     string        `yaml:"validation,omitempty"`
+	ValidatorFlags string        `yaml:"validator_flags,omitempty"`
+	Limits         *kattisLimits `yaml:"limits,omitempty"`
+}
+
+type kattisLimits struct {
+	TimeLimit float64 `yaml:"time_limit,omitempty"` // in seconds
+	Memory    int64   `yaml:"memory,omitempty"`     // in MiB
+}
+
+// kattisExtra is oj.yaml of the packages the judge exports, it keeps what
+// problem.yaml can not so a problem is imported again without loss.
+type kattisExtra struct {
+	TimeLimit    int64             `yaml:"time_limit"`   // in ms
+	MemoryLimit  int64             `yaml:"memory_limit"` // in byte
+	Type         string            `yaml:"type"`
+	Compare      string            `yaml:"compare"`
+	Epsilon      float64           `yaml:"epsilon,omitempty"`
+	Difficulty   int               `yaml:"difficulty,omitempty"`
+	Description  string            `yaml:"description"`
+	Generators   []string          `yaml:"generators,omitempty"` // names in order, sources are generators/<name>.cpp
+	Script       string            `yaml:"script,omitempty"`
+	Subtasks     []kattisSubtask   `yaml:"subtasks,omitempty"`
+	Explanations []string          `yaml:"explanations,omitempty"`   // of samples in order
+	Main         string            `yaml:"main_solution,omitempty"`  // path of the main solution in submissions
+	Solutions    map[string]string `yaml:"solution_names,omitempty"` // names of solutions by path in submissions, unless their file names
+}
+
+type kattisSubtask struct {
+	Score float64 `yaml:"score"`
+	Rule  string  `yaml:"rule"`
+	Cases []int   `yaml:"cases"`
+}
+
+// jury programs of exported packages, testlib programs of other packages
+// are found in their directories
+const (
+	kattisChecker    = "output_validators/checker/checker.cpp"
+	kattisInteractor = "output_validators/interactor/interactor.cpp"
+	kattisValidator  = "input_format_validators/validator/validator.cpp"
+)
+
+// kattisLanguages are the languages of submissions by extension.
+var kattisLanguages = map[string]string{
+	".c":   "c",
+	".cc":  "cpp",
+	".cpp": "cpp",
+	".cxx": "cpp",
+	".go":  "go",
+}
+
+// kattisVerdicts are the directories of submissions by expected verdict,
+// verdicts Kattis does not have are in directories of their names in
+// snake case.
+var kattisVerdicts = map[model.JudgeResult]string{
+	model.Accept:              "accepted",
+	model.WrongAnswer:         "wrong_answer",
+	model.TimeLimitExceeded:   "time_limit_exceeded",
+	model.RuntimeError:        "run_time_error",
+	model.MemoryLimitExceeded: "memory_limit_exceeded",
+	model.PresentationError:   "presentation_error",
+	model.CompileError:        "compile_error",
+}
+
+func kattisVerdictDir(r model.JudgeResult) string {
+	if dir, ok := kattisVerdicts[r]; ok {
+		return dir
+	}
+	var dir []rune
+	for i, c := range r.String() {
+		if unicode.IsUpper(c) && i > 0 {
+			dir = append(dir, '_')
+		}
+		dir = append(dir, unicode.ToLower(c))
+	}
+	return string(dir)
+}
+
+// kattisVerdict returns the expected verdict of submissions in dir.
+func kattisVerdict(dir string) (model.JudgeResult, bool) {
+	for r := model.Accept; r <= model.SystemError; r++ {
+		if r != model.Handling && kattisVerdictDir(r) == dir {
+			return r, true
+		}
+	}
+	return model.Unhandled, false
+}
+
+// yamlText returns a text of problem.yaml, which is a string, a list of
+// strings, or a map preferring English or a name.
+func yamlText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		var texts []string
+		for _, e := range v {
+			if text := yamlText(e); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		return strings.Join(texts, ", ")
+	case map[interface{}]interface{}:
+		for _, key := range []string{"en", "name"} {
+			if e, ok := v[key]; ok {
+				return yamlText(e)
+			}
+		}
+		var first interface{}
+		for key := range v {
+			if first == nil || fmt.Sprint(key) < fmt.Sprint(first) {
+				first = key
+			}
+		}
+		return yamlText(v[first])
+	}
+	return fmt.Sprint(v)
+}
+
+// yamlList returns a list of problem.yaml, which is a list or a string of
+// words.
+func yamlList(v interface{}) []string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return strings.Fields(yamlText(v))
+	}
+	var texts []string
+	for _, e := range list {
+		if text := yamlText(e); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
+
+// readKattis reads a Kattis package extracted in dir. A package exported by
+// the judge has oj.yaml and is read without loss, other packages are read as
+// far as the judge has their features.
+func readKattis(dir string) (*imported, error) {
+	root, err := packageRoot(dir, "problem.yaml")
+	if err != nil {
+		return nil, err
+	}
+	data, err := readPackageFile(root, "problem.yaml")
+	if err != nil {
+		return nil, err
+	}
+	var config kattisConfig
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		return nil, badPackage("problem.yaml: %v", err)
+	}
+	var extra *kattisExtra
+	data, err = readPackageFile(root, "oj.yaml")
+	switch err.(type) {
+	case nil:
+		extra = new(kattisExtra)
+		if err := yaml.Unmarshal([]byte(data), extra); err != nil {
+			return nil, badPackage("oj.yaml: %v", err)
+		}
+	case *packageError:
+	default:
+		return nil, err
+	}
+
+	imp := &imported{Attachments: make(map[string]string)}
+	problem := &imp.Problem
+	problem.Title = yamlText(config.Name)
+	problem.Author = yamlText(config.Author)
+	problem.Source = yamlText(config.Source)
+	problem.Tags = yamlList(config.Keywords)
+	if extra != nil {
+		err = readKattisExtra(imp, root, extra)
+	} else {
+		err = readKattisConfig(imp, root, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := readKattisTests(imp, root, extra); err != nil {
+		return nil, err
+	}
+	if err := readKattisSubmissions(imp, root, extra); err != nil {
+		return nil, err
+	}
+	if err := readKattisStatements(imp, root); err != nil {
+		return nil, err
+	}
+	if problem.Title == "" {
+		problem.Title = filepath.Base(root)
+	}
+	if extra == nil {
+		problem.Description = problem.Title
+		if len(problem.Statements) > 0 && problem.Statements[0].Legend != "" {
+			problem.Description = problem.Statements[0].Legend
+		}
+	}
+	return imp, nil
+}
+
+// readKattisExtra reads what oj.yaml keeps and the jury programs of a
+// package exported by the judge.
+func readKattisExtra(imp *imported, root string, extra *kattisExtra) error {
+	problem := &imp.Problem
+	problem.TimeLimit, problem.MemoryLimit = extra.TimeLimit, extra.MemoryLimit
+	problem.Type, problem.Compare, problem.Epsilon = extra.Type, extra.Compare, extra.Epsilon
+	problem.Difficulty, problem.Description = extra.Difficulty, extra.Description
+	problem.Script = extra.Script
+	for _, s := range extra.Subtasks {
+		problem.Subtasks = append(problem.Subtasks, model.Subtask{Score: s.Score, Rule: s.Rule, Cases: s.Cases})
+	}
+	for _, j := range []struct {
+		name string
+		dst  *string
+	}{
+		{kattisChecker, &problem.Checker},
+		{kattisInteractor, &problem.Interactor},
+		{kattisValidator, &problem.Validator},
+	} {
+		source, err := readPackageFile(root, j.name)
+		if _, ok := err.(*packageError); ok {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		*j.dst = source
+	}
+	for _, name := range extra.Generators {
+		source, err := readPackageFile(root, "generators/"+name+".cpp")
+		if err != nil {
+			return err
+		}
+		problem.Generators = append(problem.Generators, model.Generator{Name: name, Source: source})
+	}
+	return nil
+}
+
+// readKattisConfig reads the limits and the judging of problem.yaml of a
+// package of another judge. Only testlib programs are imported as jury
+// programs, Kattis validators talk another protocol.
+func readKattisConfig(imp *imported, root string, config kattisConfig) error {
+	problem := &imp.Problem
+	problem.MemoryLimit = 2048 << 20
+	if config.Limits != nil && config.Limits.Memory > 0 {
+		problem.MemoryLimit = config.Limits.Memory << 20
+	}
+	if config.Limits != nil && config.Limits.TimeLimit > 0 {
+		problem.TimeLimit = int64(math.Ceil(config.Limits.TimeLimit * 1000))
+	} else if data, err := readPackageFile(root, ".timelimit"); err == nil {
+		if seconds, err := strconv.ParseFloat(strings.TrimSpace(data), 64); err == nil && seconds > 0 {
+			problem.TimeLimit = int64(math.Ceil(seconds * 1000))
+		}
+	}
+	if problem.TimeLimit == 0 {
+		problem.TimeLimit = 1000
+		imp.report("the package has no time limit, it is 1 second")
+	}
+
+	var custom, interactive bool
+	for _, v := range strings.Fields(config.Validation) {
+		switch v {
+		case "default":
+		case "custom":
+			custom = true
+		case "interactive":
+			interactive = true
+		default:
+			imp.report("validation %s is not imported", v)
+		}
+	}
+	validators := []string{"output_validators", "output_validator"}
+	switch {
+	case interactive:
+		source, err := readKattisTestlib(imp, root, validators, "interactor")
+		if err != nil {
+			return err
+		}
+		if source == "" {
+			return badPackage("interactive problems need a testlib interactor in output_validators")
+		}
+		problem.Type, problem.Interactor = model.Interactive, source
+	case custom:
+		source, err := readKattisTestlib(imp, root, validators, "checker")
+		if err != nil {
+			return err
+		}
+		problem.Checker = source
+		if source == "" {
+			problem.Compare = model.CompareTokens
+			imp.report("outputs are compared by tokens instead of the output validator")
+		}
+	default:
+		problem.Compare, problem.Epsilon = kattisCompare(imp, config.ValidatorFlags)
+	}
+	source, err := readKattisTestlib(imp, root, []string{"input_format_validators", "input_validators"}, "validator")
+	if err != nil {
+		return err
+	}
+	problem.Validator = source
+	if _, err := os.Stat(packagePath(root, "generators")); err == nil {
+		imp.report("generators are not imported")
+	}
+	return nil
+}
+
+// kattisCompare returns the comparison mode and the epsilon of validator
+// flags of the default output validator.
+func kattisCompare(imp *imported, flags string) (string, float64) {
+	var (
+		caseSensitive, spaceSensitive bool
+		epsilon                       float64
+	)
+	fields := strings.Fields(flags)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "case_sensitive":
+			caseSensitive = true
+		case "space_change_sensitive":
+			spaceSensitive = true
+		case "float_tolerance", "float_absolute_tolerance", "float_relative_tolerance":
+			if i+1 < len(fields) {
+				i++
+				if e, err := strconv.ParseFloat(fields[i], 64); err == nil && e > epsilon {
+					epsilon = e
+				}
+			}
+		default:
+			imp.report("validator flag %s is not imported", fields[i])
+		}
+	}
+	switch {
+	case epsilon > 0:
+		return model.CompareFloat, epsilon
+	case spaceSensitive:
+		if !caseSensitive {
+			imp.report("outputs are compared exactly, case sensitive")
+		}
+		return model.CompareExact, 0
+	case caseSensitive:
+		return model.CompareTokens, 0
+	}
+	return model.CompareTokensCI, 0
+}
+
+// readKattisTestlib returns the source of the testlib program in dirs of
+// the package at root, the program what of the problem. Other programs are
+// reported.
+func readKattisTestlib(imp *imported, root string, dirs []string, what string) (string, error) {
+	var files []string
+	for _, dir := range dirs {
+		base := packagePath(root, dir)
+		err := filepath.Walk(base, func(name string, info os.FileInfo, err error) error {
+			if err != nil {
+				if name == base && os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if !info.IsDir() {
+				rel, err := filepath.Rel(root, name)
+				files = append(files, filepath.ToSlash(rel))
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	var found string
+	for _, name := range files {
+		switch ext := strings.ToLower(path.Ext(name)); {
+		case ext == ".h" || ext == ".hpp":
+			continue
+		case kattisLanguages[ext] != "cpp":
+			imp.report("%s is not a C++ testlib %s, it is not imported", name, what)
+			continue
+		}
+		source, err := readPackageFile(root, name)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case !strings.Contains(source, "testlib.h"):
+			imp.report("%s is not a testlib %s, it is not imported", name, what)
+		case found != "":
+			imp.report("%s is not imported, a problem has one %s", name, what)
+		default:
+			found = source
+		}
+	}
+	return found, nil
+}
+
+// readKattisTests reads the tests of the package at root. The test cases of
+// an exported package are in data/secret, other packages are judged on
+// data/sample too. Tests in data/sample are the samples.
+func readKattisTests(imp *imported, root string, extra *kattisExtra) error {
+	var total int64
+	samples, err := readKattisData(imp, root, "data/sample", &total)
+	if err != nil {
+		return err
+	}
+	secret, err := readKattisData(imp, root, "data/secret", &total)
+	if err != nil {
+		return err
+	}
+	cases := secret
+	if extra == nil {
+		cases = append(samples, secret...)
+	}
+	if len(cases) == 0 {
+		return badPackage("no tests in data")
+	}
+	for i := range cases {
+		cases[i].Nth = i + 1
+	}
+	imp.Cases = cases
+	for i, t := range samples {
+		input, err := readBlob(t.InputPath())
+		if err != nil {
+			return err
+		}
+		output, err := readBlob(t.OutputPath())
+		if err != nil {
+			return err
+		}
+		sample := model.Sample{Input: input, Output: output}
+		if extra != nil && i < len(extra.Explanations) {
+			sample.Explanation = extra.Explanations[i]
+		}
+		imp.Problem.Samples = append(imp.Problem.Samples, sample)
+	}
+	return nil
+}
+
+// readKattisData saves the tests in dir of the package at root in natural
+// order, adding their sizes to total.
+func readKattisData(imp *imported, root, dir string, total *int64) ([]model.TestCase, error) {
+	files := make(map[string]int64)
+	base := packagePath(root, dir)
+	err := filepath.Walk(base, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			if name == base && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			rel, err := filepath.Rel(base, name)
+			files[filepath.ToSlash(rel)] = info.Size()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	pairs, report := pairTests(files)
+	if len(report.Unpaired) > 0 {
+		imp.report("tests %v in %s have no pairs, they are not imported", report.Unpaired, dir)
+	}
+	if len(report.Duplicate) > 0 {
+		imp.report("tests %v in %s are duplicates, they are not imported", report.Duplicate, dir)
+	}
+	if len(report.Ignored) > 0 {
+		imp.report("files %v in %s are not tests, they are not imported", report.Ignored, dir)
+	}
+	var cases []model.TestCase
+	for _, p := range pairs {
+		var t model.TestCase
+		for _, part := range []struct{ name, file string }{{"input", p.Input}, {"output", p.Output}} {
+			digest, size, err := savePackageFile(root, path.Join(dir, part.file), dataQuota-*total)
+			if err != nil {
+				return nil, err
+			}
+			*total += size
+			t.Set(part.name, digest, size)
+		}
+		cases = append(cases, t)
+	}
+	return cases, nil
+}
+
+// readKattisSubmissions reads the submissions of the package at root as
+// reference solutions expecting the verdicts of their directories. The main
+// solution of a package of another judge is the first accepted one.
+func readKattisSubmissions(imp *imported, root string, extra *kattisExtra) error {
+	dirs, err := ioutil.ReadDir(packagePath(root, "submissions"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	main := -1
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		expected, ok := kattisVerdict(d.Name())
+		if !ok {
+			imp.report("submissions in %s have no expected verdict, they are not imported", d.Name())
+			continue
+		}
+		infos, err := ioutil.ReadDir(packagePath(root, path.Join("submissions", d.Name())))
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			file := path.Join(d.Name(), info.Name())
+			if info.IsDir() {
+				imp.report("submission %s of several files is not imported", file)
+				continue
+			}
+			lang, ok := kattisLanguages[strings.ToLower(path.Ext(file))]
+			if !ok {
+				imp.report("submission %s is not in C, C++ or Go, it is not imported", file)
+				continue
+			}
+			name := info.Name()
+			if extra != nil && extra.Solutions[file] != "" {
+				name = extra.Solutions[file]
+			}
+			if names[name] {
+				imp.report("submission %s is not imported, another solution is named %s", file, name)
+				continue
+			}
+			names[name] = true
+			source, err := readPackageFile(root, path.Join("submissions", file))
+			if err != nil {
+				return err
+			}
+			if main < 0 && (extra != nil && file == extra.Main || extra == nil && expected == model.Accept) {
+				main = len(imp.Solutions)
+				if extra == nil {
+					imp.report("submission %s is the main solution", file)
+				}
+			}
+			imp.Solutions = append(imp.Solutions, model.Solution{
+				Name:   name,
+				Lang:   lang,
+				Source: source,
+				Expect: expected.String(),
+			})
+		}
+	}
+	if main >= 0 {
+		imp.Solutions[main].Main = true
+	}
+	return nil
+}
+
+var (
+	kattisStatement = regexp.MustCompile(`^problem(?:\.([a-zA-Z0-9-]+))?\.(md|tex)$`)
+	kattisSection   = regexp.MustCompile(`(?mi)^#{2,3}[ \t]+(input|output|notes?|interaction)[ \t]*$`)
+	texProblemName  = regexp.MustCompile(`\\problemname\{([^{}]*)\}`)
+	texSection      = regexp.MustCompile(`(?i)\\section\*?\{(input|output|notes?|interaction)\}`)
+	relativeLink    = regexp.MustCompile(`\]\(([^()\s:]+)\)`)
+	attachmentLink  = regexp.MustCompile(`\]\(attachment:([^()\s]+)\)`)
+)
+
+// readKattisStatements reads the statements of the package at root in
+// Markdown, or in LaTeX unless in Markdown, other files of statements are
+// attachments. A statement without a language is in English.
+func readKattisStatements(imp *imported, root string) error {
+	problem := &imp.Problem
+	locales := make(map[string]bool)
+	var tex bool
+	for _, dir := range []string{"problem_statement", "statement"} {
+		infos, err := ioutil.ReadDir(packagePath(root, dir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var files []string
+		for _, info := range infos {
+			name := info.Name()
+			switch {
+			case info.IsDir():
+				imp.report("directory %s of statements is not imported", path.Join(dir, name))
+			case kattisStatement.MatchString(name):
+				files = append(files, name)
+			case model.CheckAttachmentName(name) == nil:
+				if _, ok := imp.Attachments[name]; !ok {
+					imp.Attachments[name] = packagePath(root, path.Join(dir, name))
+				}
+			default:
+				imp.report("file %s of statements is not imported", path.Join(dir, name))
+			}
+		}
+		for _, format := range []string{"md", "tex"} {
+			for _, name := range files {
+				m := kattisStatement.FindStringSubmatch(name)
+				if m[2] != format {
+					continue
+				}
+				locale := "en"
+				if m[1] != "" {
+					if locale, err = model.CanonicalLocale(m[1]); err != nil {
+						imp.report("statement %s is not imported", path.Join(dir, name))
+						continue
+					}
+				}
+				if locales[locale] {
+					imp.report("statement %s is not imported, there is another in %s", path.Join(dir, name), locale)
+					continue
+				}
+				locales[locale] = true
+				content, err := readPackageFile(root, path.Join(dir, name))
+				if err != nil {
+					return err
+				}
+				if format == "tex" {
+					tex = true
+					if m := texProblemName.FindStringSubmatch(content); m != nil && problem.Title == "" {
+						problem.Title = strings.TrimSpace(m[1])
+					}
+					content = texProblemName.ReplaceAllString(content, "")
+					content = texToMarkdown(texSection.ReplaceAllString(content, "\n## $1\n"))
+				}
+				statement := markdownStatement(content)
+				statement.Locale = locale
+				for _, section := range []*string{&statement.Legend, &statement.Input, &statement.Output, &statement.Notes} {
+					*section = relativeLink.ReplaceAllStringFunc(*section, func(link string) string {
+						name := relativeLink.FindStringSubmatch(link)[1]
+						if _, ok := imp.Attachments[name]; ok {
+							return "](attachment:" + name + ")"
+						}
+						return link
+					})
+				}
+				problem.Statements = append(problem.Statements, statement)
+			}
+		}
+	}
+	if tex {
+		imp.report("statements are converted from LaTeX, check their formatting")
+	}
+	return nil
+}
+
+// markdownStatement splits a Markdown statement of a Kattis package into
+// sections by their headings, the interaction is a part of the notes.
+func markdownStatement(md string) model.Statement {
+	sections := make(map[string]string)
+	section, start := "legend", 0
+	for _, m := range kattisSection.FindAllStringSubmatchIndex(md, -1) {
+		sections[section] += md[start:m[0]]
+		section, start = strings.TrimSuffix(strings.ToLower(md[m[2]:m[3]]), "s"), m[1]
+	}
+	sections[section] += md[start:]
+	statement := model.Statement{
+		Legend: strings.TrimSpace(sections["legend"]),
+		Input:  strings.TrimSpace(sections["input"]),
+		Output: strings.TrimSpace(sections["output"]),
+		Notes:  strings.TrimSpace(sections["note"]),
+	}
+	if interaction := strings.TrimSpace(sections["interaction"]); interaction != "" {
+		statement.Notes = strings.TrimSpace(statement.Notes + "\n\n### Interaction\n\n" + interaction)
+	}
+	return statement
+}
+
+// kattisMarkdown returns a statement in Markdown of a Kattis package, links
+// to attachments are relative to the statement.
+func kattisMarkdown(s model.Statement) string {
+	var b bytes.Buffer
+	b.WriteString(s.Legend)
+	for _, section := range []struct{ heading, content string }{
+		{"Input", s.Input},
+		{"Output", s.Output},
+		{"Notes", s.Notes},
+	} {
+		if section.content != "" {
+			fmt.Fprintf(&b, "\n\n## %s\n\n%s", section.heading, section.content)
+		}
+	}
+	b.WriteString("\n")
+	return attachmentLink.ReplaceAllString(b.String(), "]($1)")
+}
+
+// kattisFlags returns the validator flags of the default output validator
+// comparing like problem.
+func kattisFlags(problem model.Problem) string {
+	switch problem.Compare {
+	case model.CompareTokensCI:
+		return ""
+	case model.CompareTokens:
+		return "case_sensitive"
+	case model.CompareFloat:
+		return "float_tolerance " + strconv.FormatFloat(problem.Epsilon, 'g', -1, 64)
+	}
+	return "case_sensitive space_change_sensitive"
+}
+
+// kattisZip writes files of a package, keeping the first error.
+type kattisZip struct {
+	w   *zip.Writer
+	err error
+}
+
+func (z *kattisZip) copy(name string, r io.Reader) {
+	if z.err != nil {
+		return
+	}
+	var f io.Writer
+	if f, z.err = z.w.Create(name); z.err == nil {
+		_, z.err = io.Copy(f, r)
+	}
+}
+
+func (z *kattisZip) write(name, content string) {
+	z.copy(name, strings.NewReader(content))
+}
+
+// copyBlob copies the file key in the storage.
+func (z *kattisZip) copyBlob(name, key string) {
+	if z.err != nil {
+		return
+	}
+	var r io.ReadCloser
+	if r, z.err = GetFile(key); z.err == nil {
+		z.copy(name, r)
+		r.Close()
+	}
+}
+
+func (z *kattisZip) yaml(name string, v interface{}) {
+	if z.err != nil {
+		return
+	}
+	var data []byte
+	if data, z.err = yaml.Marshal(v); z.err == nil {
+		z.write(name, string(data))
+	}
+}
+
+// writeKattis writes an exported problem as a zipped Kattis package, with
+// oj.yaml keeping what problem.yaml can not.
+func writeKattis(w io.Writer, p *imported) error {
+	problem := p.Problem
+	config := kattisConfig{
+		Name: problem.Title,
+		Limits: &kattisLimits{
+			TimeLimit: float64(problem.TimeLimit) / 1000,
+			Memory:    (problem.MemoryLimit + 1<<20 - 1) >> 20,
+		},
+	}
+	if problem.Author != "" {
+		config.Author = problem.Author
+	}
+	if problem.Source != "" {
+		config.Source = problem.Source
+	}
+	if len(problem.Tags) > 0 {
+		config.Keywords = problem.Tags
+	}
+	switch {
+	case problem.Type == model.Interactive:
+		config.Validation = "custom interactive"
+	case problem.Checker != "":
+		config.Validation = "custom"
+	default:
+		config.Validation = "default"
+		config.ValidatorFlags = kattisFlags(problem)
+	}
+	extra := kattisExtra{
+		TimeLimit:   problem.TimeLimit,
+		MemoryLimit: problem.MemoryLimit,
+		Type:        problem.Type,
+		Compare:     problem.Compare,
+		Epsilon:     problem.Epsilon,
+		Difficulty:  problem.Difficulty,
+		Description: problem.Description,
+		Script:      problem.Script,
+		Solutions:   make(map[string]string),
+	}
+	for _, g := range problem.Generators {
+		extra.Generators = append(extra.Generators, g.Name)
+	}
+	for _, s := range problem.Subtasks {
+		extra.Subtasks = append(extra.Subtasks, kattisSubtask{Score: s.Score, Rule: s.Rule, Cases: s.Cases})
+	}
+	for _, s := range problem.Samples {
+		if s.Explanation != "" {
+			for _, s := range problem.Samples {
+				extra.Explanations = append(extra.Explanations, s.Explanation)
+			}
+			break
+		}
+	}
+	submissions := make([]string, len(p.Solutions))
+	for i, s := range p.Solutions {
+		expected, err := model.ParseJudgeResult(s.Expect)
+		if err != nil {
+			return err
+		}
+		file := s.Name
+		if kattisLanguages[strings.ToLower(path.Ext(file))] != s.Lang {
+			file += "." + s.Lang
+		}
+		if file != s.Name {
+			extra.Solutions[path.Join(kattisVerdictDir(expected), file)] = s.Name
+		}
+		submissions[i] = path.Join(kattisVerdictDir(expected), file)
+		if s.Main {
+			extra.Main = submissions[i]
+		}
+	}
+
+	z := &kattisZip{w: zip.NewWriter(w)}
+	z.yaml("problem.yaml", config)
+	z.yaml("oj.yaml", extra)
+	for _, j := range []struct{ name, source string }{
+		{kattisChecker, problem.Checker},
+		{kattisInteractor, problem.Interactor},
+		{kattisValidator, problem.Validator},
+	} {
+		if j.source != "" {
+			z.write(j.name, j.source)
+		}
+	}
+	for _, g := range problem.Generators {
+		z.write("generators/"+g.Name+".cpp", g.Source)
+	}
+	width := len(strconv.Itoa(len(p.Cases)))
+	for i, t := range p.Cases {
+		name := fmt.Sprintf("data/secret/%0*d", width, i+1)
+		z.copyBlob(name+".in", t.InputPath())
+		z.copyBlob(name+".ans", t.OutputPath())
+	}
+	width = len(strconv.Itoa(len(problem.Samples)))
+	for i, s := range problem.Samples {
+		name := fmt.Sprintf("data/sample/%0*d", width, i+1)
+		z.write(name+".in", s.Input)
+		z.write(name+".ans", s.Output)
+	}
+	for _, s := range problem.Statements {
+		z.write("problem_statement/problem."+s.Locale+".md", kattisMarkdown(s))
+	}
+	var names []string
+	for name := range p.Attachments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		z.copyBlob("problem_statement/"+name, p.Attachments[name])
+	}
+	for i, s := range p.Solutions {
+		z.write("submissions/"+submissions[i], s.Source)
+	}
+	if z.err != nil {
+		return z.err
+	}
+	return z.w.Close()
+}
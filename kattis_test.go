@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+// exported returns a problem as exportProblem does, with its tests and
+// attachments in the storage.
+func exported(t *testing.T) *imported {
+	var cases []model.TestCase
+	for i, test := range [][2]string{{"1 2\n", "3\n"}, {"10 20\n", "30\n"}} {
+		c := model.TestCase{Nth: i + 1}
+		for j, part := range []string{"input", "output"} {
+			digest, err := SaveBlob("problems", test[j])
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Set(part, digest, int64(len(test[j])))
+		}
+		cases = append(cases, c)
+	}
+	if err := store.Put("attachments/sum", bytes.NewReader([]byte("\x89PNG\r\n\x1a\n")), 8); err != nil {
+		t.Fatal(err)
+	}
+	return &imported{
+		Problem: model.Problem{
+			Title:       "A+B",
+			TimeLimit:   1500,
+			MemoryLimit: 64<<20 + 1,
+			Description: "Add two numbers.",
+			Compare:     model.CompareFloat,
+			Epsilon:     1e-6,
+			Checker:     "// check",
+			Validator:   "// val",
+			Generators:  []model.Generator{{Name: "rand", Source: "// rand"}, {Name: "big", Source: "// big"}},
+			Script:      "rand 1\nbig\n",
+			Difficulty:  3,
+			Source:      "Contest 2016",
+			Author:      "Alice",
+			Tags:        []string{"math", "two pointers"},
+			Subtasks: []model.Subtask{
+				{Score: 40, Rule: model.AllOrNothing, Cases: []int{1}},
+				{Score: 60, Rule: model.SumScore, Cases: []int{2}},
+			},
+			Samples: []model.Sample{{Input: "1 2\n", Output: "3\n", Explanation: "1 and 2 make 3."}},
+			Statements: []model.Statement{
+				{Locale: "en", Legend: "Add $a$ and $b$.\n\n![](attachment:sum.png)", Input: "Two numbers.", Output: "The sum."},
+				{Locale: "zh-CN", Legend: "求和。", Input: "两个数。", Output: "和。", Notes: "### Scoring\n\n没有。"},
+			},
+		},
+		Cases: cases,
+		Solutions: []model.Solution{
+			{Name: "main", Lang: "cpp", Source: "// main", Expect: "Accept", Main: true},
+			{Name: "slow.go", Lang: "go", Source: "// slow", Expect: "TimeLimitExceeded"},
+			{Name: "panic.c", Lang: "c", Source: "// panic", Expect: "PanicError"},
+		},
+		Attachments: map[string]string{"sum.png": "attachments/sum"},
+	}
+}
+
+// extractKattis extracts a zipped Kattis package into a temporary directory.
+func extractKattis(t *testing.T, archive []byte) string {
+	dir, err := ioutil.TempDir("", "kattis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractFiles(bytes.NewReader(archive), dir, 1<<20, false); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestKattisRoundTrip(t *testing.T) {
+	store = storage.NewMemory()
+	exp := exported(t)
+	var archive bytes.Buffer
+	if err := writeKattis(&archive, exp); err != nil {
+		t.Fatal(err)
+	}
+	dir := extractKattis(t, archive.Bytes())
+	defer os.RemoveAll(dir)
+	imp, err := readKattis(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imp.Report) > 0 {
+		t.Errorf("report %q of an exported package", imp.Report)
+	}
+	if !reflect.DeepEqual(imp.Problem, exp.Problem) {
+		t.Errorf("get problem %+v, want %+v", imp.Problem, exp.Problem)
+	}
+	if !reflect.DeepEqual(imp.Cases, exp.Cases) {
+		t.Errorf("get test cases %+v, want %+v", imp.Cases, exp.Cases)
+	}
+	solutions := make(map[string]model.Solution)
+	for _, s := range imp.Solutions {
+		solutions[s.Name] = s
+	}
+	for _, want := range exp.Solutions {
+		if s := solutions[want.Name]; !reflect.DeepEqual(s, want) {
+			t.Errorf("get solution %+v, want %+v", s, want)
+		}
+	}
+	if len(imp.Solutions) != len(exp.Solutions) {
+		t.Errorf("get %d solutions, want %d", len(imp.Solutions), len(exp.Solutions))
+	}
+	if len(imp.Attachments) != 1 {
+		t.Fatalf("get attachments %v", imp.Attachments)
+	}
+	if data, err := ioutil.ReadFile(imp.Attachments["sum.png"]); err != nil || string(data) != "\x89PNG\r\n\x1a\n" {
+		t.Errorf("get attachment %q: %v", data, err)
+	}
+}
+
+func TestReadKattis(t *testing.T) {
+	store = storage.NewMemory()
+	files := map[string]string{
+		"aplusb/problem.yaml": "name:\n  en: A+B\n  sv: A plus B\nauthor: [Alice, Bob]\n" +
+			"source: {name: NWERC, url: 'https://example.com'}\nkeywords: math easy\n" +
+			"validator_flags: case_sensitive\nlimits:\n  memory: 256\n",
+		"aplusb/.timelimit":                            "2\n",
+		"aplusb/data/sample/1.in":                      "1 2\n",
+		"aplusb/data/sample/1.ans":                     "3\n",
+		"aplusb/data/sample/1.png":                     "",
+		"aplusb/data/secret/group1/10.in":              "10 20\n",
+		"aplusb/data/secret/group1/10.ans":             "30\n",
+		"aplusb/data/secret/group1/2.in":               "2 2\n",
+		"aplusb/data/secret/group1/2.ans":              "4\n",
+		"aplusb/input_format_validators/val/val.py":    "",
+		"aplusb/input_format_validators/val/val.cpp":   "#include \"testlib.h\"\n",
+		"aplusb/input_format_validators/val/testlib.h": "",
+		"aplusb/submissions/accepted/a.cpp":            "// a",
+		"aplusb/submissions/accepted/b.py":             "",
+		"aplusb/submissions/wrong_answer/wa.c":         "// wa",
+		"aplusb/submissions/run_time_error/a.cpp":      "// rte",
+		"aplusb/submissions/partially_accepted/p.cpp":  "",
+		"aplusb/problem_statement/problem.tex":         "\\problemname{A+B}\nAdd.\n\\includegraphics{sum.png}\n\\section*{Input}\nTwo numbers.\n\\section*{Output}\nThe sum.\n",
+		"aplusb/problem_statement/problem.sv.md":       "Addera ![](sum.png).\n\n## Input\n\nTvå tal.\n\n## Output\n\nSumman.\n\n## Interaction\n\nIngen.\n",
+		"aplusb/problem_statement/problem.en.md":       "Add.\n\n## Input\n\nTwo numbers.\n\n## Output\n\nThe sum.\n",
+		"aplusb/problem_statement/sum.png":             "\x89PNG\r\n\x1a\n",
+	}
+	dir := extractKattis(t, zipArchive(t, files))
+	defer os.RemoveAll(dir)
+	imp, err := readKattis(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problem := imp.Problem
+	if problem.Title != "A+B" || problem.Author != "Alice, Bob" || problem.Source != "NWERC" {
+		t.Errorf("get problem %q by %q from %q", problem.Title, problem.Author, problem.Source)
+	}
+	if !reflect.DeepEqual(problem.Tags, []string{"math", "easy"}) {
+		t.Errorf("get tags %v", problem.Tags)
+	}
+	if problem.TimeLimit != 2000 || problem.MemoryLimit != 256<<20 || problem.Compare != model.CompareTokens {
+		t.Errorf("get limits %d and %d, comparison %s", problem.TimeLimit, problem.MemoryLimit, problem.Compare)
+	}
+	if problem.Validator != "#include \"testlib.h\"\n" || problem.Checker != "" {
+		t.Errorf("get validator %q and checker %q", problem.Validator, problem.Checker)
+	}
+
+	// samples are judged too
+	if len(imp.Cases) != 3 {
+		t.Fatalf("get %d test cases, want 3", len(imp.Cases))
+	}
+	for i, want := range []string{"1 2\n", "2 2\n", "10 20\n"} {
+		if c := imp.Cases[i]; c.Nth != i+1 || c.InputHash != storage.Digest([]byte(want)) {
+			t.Errorf("test %d is %+v, want %q", i+1, c, want)
+		}
+	}
+	if !reflect.DeepEqual(problem.Samples, []model.Sample{{Input: "1 2\n", Output: "3\n"}}) {
+		t.Errorf("get samples %+v", problem.Samples)
+	}
+
+	wantSolutions := []model.Solution{
+		{Name: "a.cpp", Lang: "cpp", Source: "// a", Expect: "Accept", Main: true},
+		{Name: "wa.c", Lang: "c", Source: "// wa", Expect: "WrongAnswer"},
+	}
+	if !reflect.DeepEqual(imp.Solutions, wantSolutions) {
+		t.Errorf("get solutions %+v, want %+v", imp.Solutions, wantSolutions)
+	}
+
+	wantStatements := []model.Statement{
+		{Locale: "en", Legend: "Add.", Input: "Two numbers.", Output: "The sum."},
+		{Locale: "sv", Legend: "Addera ![](attachment:sum.png).", Input: "Två tal.", Output: "Summan.", Notes: "### Interaction\n\nIngen."},
+	}
+	if !reflect.DeepEqual(problem.Statements, wantStatements) {
+		t.Errorf("get statements %+v, want %+v", problem.Statements, wantStatements)
+	}
+	if problem.Description != "Add." || len(imp.Attachments) != 1 {
+		t.Errorf("get description %q and attachments %v", problem.Description, imp.Attachments)
+	}
+	for _, want := range []string{
+		"files [1.png] in data/sample are not tests, they are not imported",
+		"input_format_validators/val/val.py is not a C++ testlib validator, it is not imported",
+		"submission accepted/a.cpp is the main solution",
+		"submission accepted/b.py is not in C, C++ or Go, it is not imported",
+		"submission run_time_error/a.cpp is not imported, another solution is named a.cpp",
+		"submissions in partially_accepted have no expected verdict, they are not imported",
+		"statement problem_statement/problem.tex is not imported, there is another in en",
+	} {
+		found := false
+		for _, r := range imp.Report {
+			found = found || r == want
+		}
+		if !found {
+			t.Errorf("%q is not reported in %q", want, imp.Report)
+		}
+	}
+}
@@ -1,32 +1,61 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/itsjamie/gin-cors"
+	"github.com/urfave/cli"
 
 	"github.com/go-xorm/xorm"
 	_ "github.com/lib/pq"
 
 	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
 	"github.com/ggaaooppeenngg/validator"
 )
 
 var (
 	engine *xorm.Engine
-	inTest bool
 )
 
-func NewEngine() *gin.Engine {
+// openDB connects to the database and syncs tables.
+func openDB() {
 	var err error
 	engine, err = xorm.NewEngine("postgres", os.Getenv("DATABASE_URL"))
 	if err != nil {
 		panic(err)
 	}
-	if err := engine.Sync2(new(model.Problem), new(model.Code)); err != nil {
+	if err := engine.Sync2(new(model.Problem), new(model.Code), new(model.Upload),
+		new(model.TestCase), new(model.CodeCaseResult), new(model.Subtask), new(model.ProblemRevision),
+		new(model.Tag), new(model.ProblemTag), new(model.Statement), new(model.Attachment),
+		new(model.Sample), new(model.Solution)); err != nil {
+		panic(err)
+	}
+}
+
+// loadQuota sets dataQuota by PROBLEM_DATA_QUOTA.
+func loadQuota() error {
+	quota := os.Getenv("PROBLEM_DATA_QUOTA")
+	if quota == "" {
+		return nil
+	}
+	var err error
+	dataQuota, err = strconv.ParseInt(quota, 10, 64)
+	return err
+}
+
+// NewEngine creates the API server, files are kept in s.
+func NewEngine(s storage.Storage) *gin.Engine {
+	store = s
+	openDB()
+	if err := loadQuota(); err != nil {
 		panic(err)
 	}
 	r := gin.New()
@@ -51,35 +80,177 @@ func NewEngine() *gin.Engine {
 			c.JSON(http.StatusBadRequest, gin.H{"error": errs})
 			return
 		}
+		if err := problem.CheckCompare(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := problem.CheckType(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		transaction := engine.NewSession()
-		defer transaction.Close()
-		if err := transaction.Begin(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if int64(len(problem.Input)+len(problem.Output)) > dataQuota {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": ErrQuotaExceeded.Error()})
 			return
 		}
-		if _, err := engine.InsertOne(&problem); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// tests are content addressed, save them before the problem refers to them,
+		// large tests are uploaded later by PUT /problem/:id/tests instead
+		var cases []model.TestCase
+		if problem.Input != "" || problem.Output != "" {
+			var err error
+			cases, err = saveCases(strings.Split(problem.Input, model.DELIM), strings.Split(problem.Output, model.DELIM))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if err := checkSubtasks(problem.Subtasks, len(cases)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if err := SaveFile(problem.InputTestPath(), problem.Input); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			engine.Delete(problem)
+		if len(problem.Samples) == 0 {
+			problem.Samples = problem.LegacySamples()
+		}
+		problem.InputSample, problem.OutputSample = "", ""
+		// a new problem has no main solution to check samples
+		if err := checkSamples(problem.Samples, cases, nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if err := SaveFile(problem.OutputTestPath(), problem.Output); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			engine.Delete(problem)
+		if err := checkStatements(problem.Statements); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := problem.CheckGenerators(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := saveJury(&problem); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !validTests(c, problem, cases) {
 			return
 		}
-		if err := transaction.Commit(); err != nil {
 
+		if err := insertProblem(&problem, cases, nil, nil); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"id": problem.Id})
 	})
 
+	// PUT /problem/:id changes the statement and limits of a problem
+	r.PUT("/problem/:id", putProblem)
+
+	// GET /problem/:id/statements lists statements of a problem in Markdown
+	r.GET("/problem/:id/statements", listStatements)
+
+	// PUT /problem/:id/statements/:locale adds or replaces the statement in a locale like "zh-CN"
+	r.PUT("/problem/:id/statements/:locale", putStatement)
+
+	// DELETE /problem/:id/statements/:locale removes the statement in a locale
+	r.DELETE("/problem/:id/statements/:locale", deleteStatement)
+
+	// PUT /problem/:id/validator replaces the validator of inputs with the C++ source in the body
+	r.PUT("/problem/:id/validator", putJury("validator", "validator_hash"))
+
+	// DELETE /problem/:id/validator removes the validator
+	r.DELETE("/problem/:id/validator", deleteJury("validator_hash"))
+
+	// POST /problem/:id/validate validates inputs of test cases by the validator
+	r.POST("/problem/:id/validate", validate)
+
+	// PUT /problem/:id/generators replaces generators and the script generating test cases
+	r.PUT("/problem/:id/generators", putGenerators)
+
+	// POST /problem/:id/generate replaces test cases with generated inputs and outputs of the solution in the body,
+	// or of the main solution without a body
+	r.POST("/problem/:id/generate", generate)
+
+	// GET /problem/:id/solutions lists reference solutions with their expected and latest verdicts
+	r.GET("/problem/:id/solutions", listSolutions)
+
+	// PUT /problem/:id/solutions/:name adds or replaces a reference solution expected to get a verdict
+	r.PUT("/problem/:id/solutions/:name", putSolution)
+
+	// DELETE /problem/:id/solutions/:name removes a reference solution
+	r.DELETE("/problem/:id/solutions/:name", deleteSolution)
+
+	// POST /problem/:id/verify judges reference solutions again
+	r.POST("/problem/:id/verify", verifyHandler)
+
+	// POST /problem/:id/answer replaces outputs of test cases with outputs of the main solution
+	r.POST("/problem/:id/answer", answer)
+
+	// GET /problem/:id/samples gets samples of a problem with the test cases they are
+	r.GET("/problem/:id/samples", getSamples)
+
+	// PUT /problem/:id/samples replaces samples, a sample with the input of a test case must have its output
+	r.PUT("/problem/:id/samples", putSamples)
+
+	// GET /problem/:id/attachments lists attachments of a problem
+	r.GET("/problem/:id/attachments", listAttachments)
+
+	// PUT /problem/:id/attachments/:name adds or replaces an attachment with the body,
+	// statements refer to it as "attachment:name"
+	r.PUT("/problem/:id/attachments/:name", putAttachment)
+
+	// GET /problem/:id/attachments/:name downloads an attachment
+	r.GET("/problem/:id/attachments/:name", getAttachmentHandler)
+
+	// DELETE /problem/:id/attachments/:name removes an attachment
+	r.DELETE("/problem/:id/attachments/:name", deleteAttachment)
+
+	// PUT /problem/:id/meta sets difficulty, source, author and tags of a problem without a revision
+	r.PUT("/problem/:id/meta", putMeta)
+
+	// GET /problem/:id/revisions lists revisions of a problem, every change of a problem makes a revision
+	r.GET("/problem/:id/revisions", listRevisions)
+
+	// GET /problem/:id/revisions/:rev gets a revision of a problem
+	r.GET("/problem/:id/revisions/:rev", getRevisionHandler)
+
+	// POST /problem/:id/revisions/:rev/rollback restores a problem to a revision as a new revision
+	r.POST("/problem/:id/revisions/:rev/rollback", rollback)
+
+	// GET /problem/:id/diff?from=1&to=2 gets changes between two revisions
+	r.GET("/problem/:id/diff", diff)
+
+	// PUT /problem/:id/tests replaces test cases with multipart/form-data parts "input" and "output"
+	r.PUT("/problem/:id/tests", putTests)
+
+	// PUT /problem/:id/archive replaces tests with test cases in a zip or tar.gz archive
+	r.PUT("/problem/:id/archive", putArchive)
+
+	// PUT /problem/:id/subtasks replaces subtasks with subtasks on uploaded test cases
+	r.PUT("/problem/:id/subtasks", putSubtasks)
+
+	// PUT /problem/:id/checker replaces the checker with the C++ source in the body
+	r.PUT("/problem/:id/checker", putJury("checker", "checker_hash"))
+
+	// DELETE /problem/:id/checker removes the checker
+	r.DELETE("/problem/:id/checker", deleteJury("checker_hash"))
+
+	// PUT /problem/:id/interactor replaces the interactor with the C++ source in the body
+	r.PUT("/problem/:id/interactor", putJury("interactor", "interactor_hash"))
+
+	// PUT /problem/:id/compare sets how outputs are compared without a checker
+	r.PUT("/problem/:id/compare", putCompare)
+
+	// POST /problem/:id/uploads starts a resumable upload of "input" or "output" of a test case
+	r.POST("/problem/:id/uploads", createUpload)
+
+	// GET /upload/:id gets how many bytes of an upload are received
+	r.GET("/upload/:id", func(c *gin.Context) {
+		if upload, ok := getUpload(c); ok {
+			c.JSON(http.StatusOK, gin.H{"upload": upload})
+		}
+	})
+
+	// PUT /upload/:id uploads the chunk in Content-Range
+	r.PUT("/upload/:id", putChunk)
+
 	// POST /problems gets problems by limit and start
 	r.POST("/problems", func(c *gin.Context) {
 		var problems []model.Problem
@@ -98,14 +269,62 @@ func NewEngine() *gin.Engine {
 		c.JSON(http.StatusOK, gin.H{"problems": problems})
 	})
 
-	// GET /problem/:id gets problem description
+	// POST /problems/import?format=polygon creates a problem from the package in the body, polygon or kattis,
+	// features of the package which are not imported as they are are reported
+	r.POST("/problems/import", importHandler)
+
+	// GET /problem/:id/export?format=kattis downloads the problem as a package of other judges
+	r.GET("/problem/:id/export", exportHandler)
+
+	// GET /problems?tag=dp&minDifficulty=1&sort=solved gets summaries of problems filtered by the query
+	r.GET("/problems", listProblems)
+
+	// GET /problem/:id?lang=zh-CN gets problem description, the statement is in the locale of lang,
+	// Accept-Language or the fallback locale, rendered to HTML
 	r.GET("/problem/:id", func(c *gin.Context) {
 		var problem model.Problem
 		if _, err := engine.Id(c.Param("id")).Get(&problem); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"problem": problem})
+		cases, err := casesOf(problem.Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if problem.Subtasks, err = subtasksOf(problem.Id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		tags, err := tagsOf(problem.Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		problem.Tags = tags[problem.Id]
+		statements, err := statementsOf(problem.Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		locales := make([]string, len(statements))
+		for i, statement := range statements {
+			locales[i] = statement.Locale
+		}
+		if problem.Samples, err = samplesOf(problem); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		matchSamples(problem.Samples, cases)
+		if problem.Attachments, err = attachmentsOf(problem.Id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		resp := gin.H{"problem": problem, "cases": cases, "locales": locales}
+		if statement, ok := chooseStatement(statements, preferredLocales(c)); ok {
+			resp["statement"] = renderStatement(statement)
+		}
+		c.JSON(http.StatusOK, resp)
 		return
 
 	})
@@ -117,14 +336,32 @@ func NewEngine() *gin.Engine {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if err := code.Init(); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem, ok := getProblem(c, code.ProblemId)
+		if !ok {
 			return
 		}
+		if problem.Type == model.OutputOnly {
+			if !saveOutputs(c, &code) {
+				return
+			}
+		} else {
+			if err := code.Init(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 
-		if errs := validator.Validate(code); errs != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": errs})
-			return
+			if errs := validator.Validate(code); errs != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": errs})
+				return
+			}
+			// the source is stored before the code is inserted, so the judger never
+			// picks up a code without source, a source left by a failed insert is
+			// collected by gc
+			var err error
+			if code.SourceHash, err = SaveBlob("codes", code.Source); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
 		}
 		transaction := engine.NewSession()
 		defer transaction.Close()
@@ -133,14 +370,10 @@ func NewEngine() *gin.Engine {
 			return
 		}
 
-		if _, err := engine.InsertOne(&code); err != nil {
+		if _, err := transaction.InsertOne(&code); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		if err := SaveFile(code.SourcePath(), code.Source); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
 		if err := transaction.Commit(); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -159,7 +392,8 @@ func NewEngine() *gin.Engine {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if err := engine.Limit(req.Limit, req.Start).Find(&codes); err != nil {
+		// judges of reference solutions are not submissions
+		if err := engine.Where("solution_id = 0").Limit(req.Limit, req.Start).Find(&codes); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -180,15 +414,72 @@ func NewEngine() *gin.Engine {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"code": code, "problem": problem})
+		var cases []model.CodeCaseResult
+		if err := engine.Where("code_id = ?", code.Id).Asc("nth").Find(&cases); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range cases {
+			cases[i].Verdict = cases[i].Status.String()
+		}
+		c.JSON(http.StatusOK, gin.H{"code": code, "status": code.Status.String(), "problem": problem, "cases": cases})
 		return
 
 	})
 
+	// GET /code/:id/transcript/:nth gets the transcript of the code and the interactor on a test case
+	r.GET("/code/:id/transcript/:nth", func(c *gin.Context) {
+		var result model.CodeCaseResult
+		has, err := engine.Where("code_id = ? AND nth = ?", c.Param("id"), c.Param("nth")).Get(&result)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !has || result.Transcript == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "transcript not found"})
+			return
+		}
+		r, err := GetFile(result.Transcript)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer r.Close()
+		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		c.Writer.WriteHeader(http.StatusOK)
+		io.Copy(c.Writer, r)
+	})
+
 	return r
 }
 
+func serve(c *cli.Context) error {
+	s, err := storage.FromEnv()
+	if err != nil {
+		return err
+	}
+	r := NewEngine(s)
+	return r.Run() // listen and server on 0.0.0.0:8080
+}
+
 func main() {
-	r := NewEngine()
-	r.Run() // listen and server on 0.0.0.0:8080
+	app := cli.NewApp()
+	app.Name = "OJ"
+	app.Usage = "online judge API server"
+	app.Action = serve
+	app.Commands = []cli.Command{
+		{
+			Name:   "serve",
+			Usage:  "run the API server, the default command",
+			Action: serve,
+		},
+		gcCommand,
+		migrateCommand,
+		importCommand,
+		exportCommand,
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/urfave/cli"
+
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+var migrateCommand = cli.Command{
+	Name:  "migrate",
+	Usage: "copy files of problems, codes and uploads to the storage configured by DEST_ prefixed variables",
+	Description: `Files already in the destination are skipped, so an interrupted migration
+   can be run again. Run the API with STORAGE_DUAL_WRITE=1 meanwhile so new
+   files are written to both storages. Chunks of uploads in flight are copied
+   too, so uploads resume after the cutover.`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "verify",
+			Usage: "also verify checksums of files already in the destination",
+		},
+	},
+	Action: migrate,
+}
+
+func migrate(c *cli.Context) error {
+	openDB()
+	src, err := storage.FromEnvPrefix("")
+	if err != nil {
+		return err
+	}
+	dst, err := storage.FromEnvPrefix("DEST_")
+	if err != nil {
+		return err
+	}
+	referenced, err := referencedKeys()
+	if err != nil {
+		return err
+	}
+	if err := referenceUploads(src, referenced); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(referenced))
+	for key := range referenced {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var copied, skipped, failed int
+	for _, key := range keys {
+		ok, err := migrateKey(src, dst, key, referenced[key], c.Bool("verify"))
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("%s\tfailed: %v\n", key, err)
+		case ok:
+			copied++
+			fmt.Printf("%s\tcopied\n", key)
+		default:
+			skipped++
+		}
+	}
+	fmt.Printf("%d copied, %d skipped, %d failed\n", copied, skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d files failed to migrate", failed)
+	}
+	return nil
+}
+
+// hashOf returns the hex encoded SHA-256 digest and size of key in s.
+func hashOf(s storage.Storage, key string) (string, int64, error) {
+	r, err := s.Get(key)
+	if err != nil {
+		return "", 0, err
+	}
+	defer r.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// migrateKey copies key from src to dst unless dst already has it, and
+// reports whether it is copied. The copy is verified against digest, or
+// against the source if digest is empty.
+func migrateKey(src, dst storage.Storage, key, digest string, verify bool) (bool, error) {
+	if info, err := dst.Stat(key); err == nil {
+		if !verify {
+			return false, nil
+		}
+		want, size, err := srcDigest(src, key, digest)
+		if err != nil {
+			return false, err
+		}
+		if got, _, err := hashOf(dst, key); err == nil && got == want && info.Size == size {
+			return false, nil
+		}
+	} else if err != storage.ErrNotExist {
+		return false, err
+	}
+
+	info, err := src.Stat(key)
+	if err == storage.ErrNotExist {
+		return false, fmt.Errorf("missing in source")
+	}
+	if err != nil {
+		return false, err
+	}
+	r, err := src.Get(key)
+	if err != nil {
+		return false, err
+	}
+	h := sha256.New()
+	err = dst.Put(key, io.TeeReader(r, h), info.Size)
+	io.Copy(ioutil.Discard, r)
+	r.Close()
+	if err != nil {
+		return false, err
+	}
+	want := hex.EncodeToString(h.Sum(nil))
+	if digest != "" && want != digest {
+		// the copy is removed, or a run without --verify would skip it
+		if err := dst.Delete(key); err != nil {
+			return false, err
+		}
+		return false, fmt.Errorf("source checksum %s, want %s", want, digest)
+	}
+	got, _, err := hashOf(dst, key)
+	if err != nil {
+		return false, err
+	}
+	if got != want {
+		dst.Delete(key)
+		return false, fmt.Errorf("destination checksum %s, want %s", got, want)
+	}
+	return true, nil
+}
+
+// srcDigest returns the digest and size key should have.
+func srcDigest(src storage.Storage, key, digest string) (string, int64, error) {
+	if digest != "" {
+		info, err := src.Stat(key)
+		return digest, info.Size, err
+	}
+	return hashOf(src, key)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+func TestMigrateKey(t *testing.T) {
+	src, dst := storage.NewMemory(), storage.NewMemory()
+	content := "1 2\n"
+	digest := storage.Digest([]byte(content))
+	src.Put("problems/1-input.txt", strings.NewReader(content), 4)
+	src.Put("problems/sha256/"+digest, strings.NewReader(content), 4)
+
+	for key, d := range map[string]string{"problems/1-input.txt": "", "problems/sha256/" + digest: digest} {
+		copied, err := migrateKey(src, dst, key, d, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !copied {
+			t.Fatalf("%s is not copied", key)
+		}
+		// migrated files are skipped when run again
+		if copied, err := migrateKey(src, dst, key, d, true); err != nil || copied {
+			t.Fatalf("%s is copied again: %v", key, err)
+		}
+	}
+
+	// a corrupted copy is copied again when verified
+	dst.Put("problems/1-input.txt", strings.NewReader("1 3\n"), 4)
+	if copied, err := migrateKey(src, dst, "problems/1-input.txt", "", false); err != nil || copied {
+		t.Fatalf("existing file is copied without verify: %v", err)
+	}
+	if copied, err := migrateKey(src, dst, "problems/1-input.txt", "", true); err != nil || !copied {
+		t.Fatalf("corrupted file is not copied again: %v", err)
+	}
+
+	// a corrupted source is not copied
+	src.Put("codes/sha256/"+digest, strings.NewReader("1 3\n"), 4)
+	if _, err := migrateKey(src, dst, "codes/sha256/"+digest, digest, false); err == nil {
+		t.Fatal("corrupted source is copied")
+	}
+	if _, err := dst.Stat("codes/sha256/" + digest); err != storage.ErrNotExist {
+		t.Fatalf("corrupted source is kept in the destination: %v", err)
+	}
+	if copied, err := migrateKey(src, dst, "codes/sha256/"+digest, digest, false); err == nil || copied {
+		t.Fatalf("corrupted source is skipped when run again: %v", err)
+	}
+}
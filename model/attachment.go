@@ -0,0 +1,35 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Attachment is a file of a problem, like a diagram in its statement or a
+// file to download, replaced when uploaded again under the same name.
+type Attachment struct {
+	Id        int64     `json:"-"`
+	ProblemId int64     `json:"-"         xorm:"unique(attachment)"`
+	Name      string    `json:"name"      xorm:"unique(attachment)"`
+	MimeType  string    `json:"mimeType"` // sniffed from the content
+	Size      int64     `json:"size"`
+	Hash      string    `json:"hash"` // SHA-256 digest of the content
+	UpdatedAt time.Time `json:"updatedAt" xorm:"updated"`
+}
+
+// attachmentName is the pattern of names of attachments, which are used in
+// paths and URLs as they are.
+var attachmentName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// CheckAttachmentName checks name of an attachment.
+func CheckAttachmentName(name string) error {
+	if !attachmentName.MatchString(name) {
+		return fmt.Errorf("invalid attachment name %q", name)
+	}
+	return nil
+}
+
+func (a Attachment) Path() string {
+	return fmt.Sprintf("problems/%d/attachments/%s", a.ProblemId, a.Name)
+}
@@ -22,6 +22,7 @@ const (
 	RuntimeError
 	PresentationError
 	PanicError
+	SystemError // the judge itself failed, e.g. corrupted test files
 )
 
 type Language int
@@ -55,24 +56,67 @@ type Code struct {
 	PanicError  string      `json:"-"`                                     // panic ouput
 	Version     int         `json:"-"         xorm:"version"`              // happy lock
 	Source      string      `json:"source"    validate:"nonzero" xorm:"-"` // source code
+	SourceHash  string      `json:"-"`                                     // SHA-256 digest of source code
+	Score       float64     `json:"score"`                                 // points scored
+	MaxScore    float64     `json:"maxScore"`                              // points of the problem
+	Revision    int         `json:"revision"`                              // revision of the problem judged on
+	UserId      int64       `json:"userId"    xorm:"index"`                // submitter, there is no authentication yet
+	SolutionId  int64       `json:"-"         xorm:"index"`                // reference solution judged, 0 for submissions
+
+	Outputs      map[int]string `json:"outputs" xorm:"-"`    // output files by nth test case of an output-only problem
+	OutputHashes map[int]string `json:"-"       xorm:"TEXT"` // SHA-256 digests of output files by nth test case
 }
 
 func (c *Code) Init() error {
-	switch c.Lang {
+	var err error
+	if c.Language, err = ParseLanguage(c.Lang); err != nil {
+		return err
+	}
+	c.CreatedAt = time.Now()
+	return nil
+}
+
+// ParseLanguage parses a language literal like "cpp".
+func ParseLanguage(lit string) (Language, error) {
+	switch lit {
 	case "c":
-		c.Language = C
+		return C, nil
 	case "cpp":
-		c.Language = CPP
+		return CPP, nil
 	case "go":
-		c.Language = Go
-	default:
-		return fmt.Errorf("unknown or unspported language %s", c.Language)
+		return Go, nil
+	}
+	return Go, fmt.Errorf("unknown or unspported language %s", lit)
+}
+
+// InitOutputs initializes a code of output files for an output-only
+// problem, which has no source or language.
+func (c *Code) InitOutputs() error {
+	if len(c.Outputs) == 0 {
+		return fmt.Errorf("no output files")
+	}
+	for nth := range c.Outputs {
+		if nth < 1 {
+			return fmt.Errorf("output file of test case %d", nth)
+		}
 	}
 	c.CreatedAt = time.Now()
 	return nil
 }
 
+func (c Code) OutputPath(nth int) string {
+	return BlobPath("codes", c.OutputHashes[nth])
+}
+
+// BlobPath returns the content addressed path of a file with digest in dir.
+func BlobPath(dir, digest string) string {
+	return fmt.Sprintf("%s/sha256/%s", dir, digest)
+}
+
 func (c Code) SourcePath() string {
+	if c.SourceHash != "" {
+		return BlobPath("codes", c.SourceHash)
+	}
 	return fmt.Sprintf("codes/%d.%s", c.Id, strings.ToLower(c.Language.String()))
 }
 
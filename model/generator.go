@@ -0,0 +1,64 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Generator is a program generating inputs of test cases, like a testlib
+// generator which prints an input decided by its arguments.
+type Generator struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"` // C++ source, kept as Hash
+	Hash   string `json:"hash"`             // SHA-256 digest of source
+}
+
+func (g Generator) Path() string {
+	return BlobPath("problems", g.Hash)
+}
+
+// ScriptLine is a line of the script of a problem, the generator named
+// Generator run with Args prints the input of a test case.
+type ScriptLine struct {
+	Generator string
+	Args      []string
+}
+
+// ParseScript parses the script of a problem, each line is the name of a
+// generator followed by its arguments separated by spaces. Empty lines and
+// lines starting with # are skipped.
+func (p Problem) ParseScript() ([]ScriptLine, error) {
+	names := make(map[string]bool)
+	for _, g := range p.Generators {
+		names[g.Name] = true
+	}
+	var lines []ScriptLine
+	for i, line := range strings.Split(p.Script, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if !names[fields[0]] {
+			return nil, fmt.Errorf("script line %d: unknown generator %s", i+1, fields[0])
+		}
+		lines = append(lines, ScriptLine{Generator: fields[0], Args: fields[1:]})
+	}
+	return lines, nil
+}
+
+// CheckGenerators checks the names of generators of the problem and its
+// script.
+func (p Problem) CheckGenerators() error {
+	names := make(map[string]bool)
+	for _, g := range p.Generators {
+		if g.Name == "" || strings.ContainsAny(g.Name, " \t\n#") {
+			return fmt.Errorf("invalid generator name %q", g.Name)
+		}
+		if names[g.Name] {
+			return fmt.Errorf("duplicate generator %s", g.Name)
+		}
+		names[g.Name] = true
+	}
+	_, err := p.ParseScript()
+	return err
+}
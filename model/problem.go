@@ -4,6 +4,22 @@ import (
 	"fmt"
 )
 
+// problem types
+const (
+	Standard    = "standard"    // the output of a program on input is judged
+	Interactive = "interactive" // a program talks with an interactor which judges it
+	OutputOnly  = "output-only" // output files are submitted instead of a program
+)
+
+// comparison modes of outputs and answers
+const (
+	CompareExact    = "exact"     // the same bytes
+	CompareTrailing = "trailing"  // the same lines ignoring trailing whitespace
+	CompareTokens   = "tokens"    // the same whitespace separated tokens
+	CompareTokensCI = "tokens-ci" // the same tokens ignoring case
+	CompareFloat    = "float"     // the same tokens, numbers within Epsilon
+)
+
 // Problem is a model of problem.
 type Problem struct {
 	Id           int64  `json:"id"`                                                        // primary key
@@ -12,17 +28,94 @@ type Problem struct {
 	TimeLimit    int64  `validate:"nonzero,min=1" json:"timeLimit"`                        // time limit in ms
 	MemoryLimit  int64  `validate:"nonzero,min=1" json:"memoryLimit"`                      // memory limit in byte
 	Description  string `validate:"nonzero"       json:"description"  xorm:"TEXT"`         // problem description
-	InputSample  string `                         json:"outputSample" xorm:"varchar(512)"` // input sample
-	OutputSample string `                         json:"intputSample" xorm:"varchar(512)"` // output sample
-	Input        string `validate:"nonzero"       json:"input"        xorm:"-"`            // input test
-	Output       string `validate:"nonzero"	      json:"output"       xorm:"-"`            // output test
+	InputSample  string `                         json:"inputSample"  xorm:"varchar(512)"` // input of the first sample, Samples are used instead
+	OutputSample string `                         json:"outputSample" xorm:"varchar(512)"` // output of the first sample, Samples are used instead
+	Input        string `                         json:"input"        xorm:"-"`            // input tests separated by DELIM, or uploaded by PUT /problem/:id/tests
+	Output       string `                         json:"output"       xorm:"-"`            // output tests separated by DELIM, or uploaded by PUT /problem/:id/tests
 	PosterId     int64  ``                                                                 // Post id TODO
+	InputHash    string `                         json:"-"`                                // SHA-256 digest of input test, only for problems without test cases
+	OutputHash   string `                         json:"-"`                                // SHA-256 digest of output test, only for problems without test cases
+	InputSize    int64  `                         json:"-"`                                // size of input test in byte
+	OutputSize   int64  `                         json:"-"`                                // size of output test in byte
+
+	Subtasks    []Subtask `json:"subtasks" xorm:"-"` // subtasks, or all or nothing on all tests
+	Checker     string    `json:"checker"  xorm:"-"` // C++ source of a testlib checker, or uploaded by PUT /problem/:id/checker
+	CheckerHash string    `json:"-"`                 // SHA-256 digest of checker source, outputs are compared by Compare without a checker
+	Compare     string    `json:"compare"`           // comparison mode, exact by default
+	Epsilon     float64   `json:"epsilon"`           // absolute or relative error of numbers in float mode
+
+	Type           string `json:"type"`                // problem type, standard by default
+	Interactor     string `json:"interactor" xorm:"-"` // C++ source of a testlib interactor, or uploaded by PUT /problem/:id/interactor
+	InteractorHash string `json:"-"`                   // SHA-256 digest of interactor source
+
+	Validator     string      `json:"validator"  xorm:"-"`    // C++ source of a testlib validator of inputs, or uploaded by PUT /problem/:id/validator
+	ValidatorHash string      `json:"-"`                      // SHA-256 digest of validator source, inputs are not validated without a validator
+	Generators    []Generator `json:"generators" xorm:"TEXT"` // generators of inputs used by Script
+	Script        string      `json:"script"     xorm:"TEXT"` // a generator and its arguments on each line for each test case
+
+	Revision     int    `json:"revision"`     // the current revision, changed by every change of the problem
+	Verification string `json:"verification"` // pending, valid or invalid by reference solutions, empty without them
+
+	Difficulty int      `json:"difficulty" xorm:"index"` // difficulty rating, higher is harder
+	Source     string   `json:"source"`                  // contest or book the problem comes from
+	Author     string   `json:"author"`                  // author of the problem
+	Tags       []string `json:"tags"       xorm:"-"`     // names of tags
+
+	Statements  []Statement  `json:"statements,omitempty"  xorm:"-"` // statements by locale, instead of description
+	Attachments []Attachment `json:"attachments,omitempty" xorm:"-"` // files of the statements by name
+	Samples     []Sample     `json:"samples"               xorm:"-"` // samples shown with the statements
 }
 
 func (p Problem) InputTestPath() string {
+	if p.InputHash != "" {
+		return BlobPath("problems", p.InputHash)
+	}
 	return fmt.Sprintf("problems/%d-input.txt", p.Id)
 }
 
 func (p Problem) OutputTestPath() string {
+	if p.OutputHash != "" {
+		return BlobPath("problems", p.OutputHash)
+	}
 	return fmt.Sprintf("problems/%d-output.txt", p.Id)
 }
+
+func (p Problem) CheckerPath() string {
+	return BlobPath("problems", p.CheckerHash)
+}
+
+func (p Problem) InteractorPath() string {
+	return BlobPath("problems", p.InteractorHash)
+}
+
+func (p Problem) ValidatorPath() string {
+	return BlobPath("problems", p.ValidatorHash)
+}
+
+// CheckType checks the type of the problem.
+func (p *Problem) CheckType() error {
+	switch p.Type {
+	case "":
+		p.Type = Standard
+	case Standard, Interactive, OutputOnly:
+	default:
+		return fmt.Errorf("unknown problem type %s", p.Type)
+	}
+	return nil
+}
+
+// CheckCompare checks the comparison mode of the problem.
+func (p *Problem) CheckCompare() error {
+	switch p.Compare {
+	case "":
+		p.Compare = CompareExact
+	case CompareExact, CompareTrailing, CompareTokens, CompareTokensCI:
+	case CompareFloat:
+		if p.Epsilon <= 0 {
+			return fmt.Errorf("float comparison needs a positive epsilon")
+		}
+	default:
+		return fmt.Errorf("unknown comparison mode %s", p.Compare)
+	}
+	return nil
+}
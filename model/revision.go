@@ -0,0 +1,98 @@
+package model
+
+import (
+	"time"
+)
+
+// ProblemRevision is an immutable snapshot of a problem with its test cases,
+// subtasks, statements and samples, a revision is made by every change of
+// the problem. Files are content addressed, so a revision keeps the tests it
+// was made with.
+type ProblemRevision struct {
+	Id        int64     `json:"-"`
+	ProblemId int64     `json:"problemId" xorm:"unique(revision)"`
+	Revision  int       `json:"revision"  xorm:"unique(revision)"`
+	CreatedAt time.Time `json:"createdAt" xorm:"created"`
+
+	Title          string      `json:"title"`
+	TimeLimit      int64       `json:"timeLimit"`
+	MemoryLimit    int64       `json:"memoryLimit"`
+	Description    string      `json:"description"    xorm:"TEXT"`
+	InputSample    string      `json:"inputSample"    xorm:"varchar(512)"`
+	OutputSample   string      `json:"outputSample"   xorm:"varchar(512)"`
+	Type           string      `json:"type"`
+	Compare        string      `json:"compare"`
+	Epsilon        float64     `json:"epsilon"`
+	CheckerHash    string      `json:"checkerHash"`
+	InteractorHash string      `json:"interactorHash"`
+	ValidatorHash  string      `json:"validatorHash"`
+	Generators     []Generator `json:"generators"     xorm:"TEXT"`
+	Script         string      `json:"script"         xorm:"TEXT"`
+	InputHash      string      `json:"inputHash"`  // legacy test of problems without test cases
+	OutputHash     string      `json:"outputHash"` // legacy test of problems without test cases
+	InputSize      int64       `json:"inputSize"`
+	OutputSize     int64       `json:"outputSize"`
+	Cases          []TestCase  `json:"cases"          xorm:"TEXT"`
+	Subtasks       []Subtask   `json:"subtasks"       xorm:"TEXT"`
+
+	Statements []Statement `json:"statements" xorm:"TEXT"`
+	Samples    []Sample    `json:"samples"    xorm:"TEXT"`
+}
+
+// NewRevision returns the snapshot of problem at its revision.
+func NewRevision(p Problem, cases []TestCase, subtasks []Subtask, statements []Statement, samples []Sample) ProblemRevision {
+	return ProblemRevision{
+		ProblemId:      p.Id,
+		Revision:       p.Revision,
+		Title:          p.Title,
+		TimeLimit:      p.TimeLimit,
+		MemoryLimit:    p.MemoryLimit,
+		Description:    p.Description,
+		InputSample:    p.InputSample,
+		OutputSample:   p.OutputSample,
+		Type:           p.Type,
+		Compare:        p.Compare,
+		Epsilon:        p.Epsilon,
+		CheckerHash:    p.CheckerHash,
+		InteractorHash: p.InteractorHash,
+		ValidatorHash:  p.ValidatorHash,
+		Generators:     p.Generators,
+		Script:         p.Script,
+		InputHash:      p.InputHash,
+		OutputHash:     p.OutputHash,
+		InputSize:      p.InputSize,
+		OutputSize:     p.OutputSize,
+		Cases:          cases,
+		Subtasks:       subtasks,
+		Statements:     statements,
+		Samples:        samples,
+	}
+}
+
+// Problem returns the problem of the revision, with its subtasks but
+// without its test cases.
+func (r ProblemRevision) Problem() Problem {
+	return Problem{
+		Id:             r.ProblemId,
+		Revision:       r.Revision,
+		Title:          r.Title,
+		TimeLimit:      r.TimeLimit,
+		MemoryLimit:    r.MemoryLimit,
+		Description:    r.Description,
+		InputSample:    r.InputSample,
+		OutputSample:   r.OutputSample,
+		Type:           r.Type,
+		Compare:        r.Compare,
+		Epsilon:        r.Epsilon,
+		CheckerHash:    r.CheckerHash,
+		InteractorHash: r.InteractorHash,
+		ValidatorHash:  r.ValidatorHash,
+		Generators:     r.Generators,
+		Script:         r.Script,
+		InputHash:      r.InputHash,
+		OutputHash:     r.OutputHash,
+		InputSize:      r.InputSize,
+		OutputSize:     r.OutputSize,
+		Subtasks:       r.Subtasks,
+	}
+}
@@ -0,0 +1,21 @@
+package model
+
+// Sample is the nth sample of a problem shown with its statements.
+type Sample struct {
+	Id          int64  `json:"-"`
+	ProblemId   int64  `json:"-"           xorm:"index"`
+	Nth         int    `json:"nth"` // 1-based order in the problem
+	Input       string `json:"input"       xorm:"TEXT"`
+	Output      string `json:"output"      xorm:"TEXT"`
+	Explanation string `json:"explanation" xorm:"TEXT"` // Markdown, may be empty
+	Test        int    `json:"test"        xorm:"-"`    // nth test case the sample is, 0 if it is none
+}
+
+// LegacySamples returns the sample of a problem made before samples, in
+// InputSample and OutputSample.
+func (p Problem) LegacySamples() []Sample {
+	if p.InputSample == "" && p.OutputSample == "" {
+		return []Sample{}
+	}
+	return []Sample{{ProblemId: p.Id, Nth: 1, Input: p.InputSample, Output: p.OutputSample}}
+}
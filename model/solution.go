@@ -0,0 +1,100 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/go-xorm/xorm"
+)
+
+// verification states of a problem with reference solutions
+const (
+	Pending = "pending" // reference solutions are being judged
+	Valid   = "valid"   // every reference solution gets its expected verdict
+	Invalid = "invalid" // some reference solution gets another verdict
+)
+
+// Solution is a reference solution of a problem with the verdict it is
+// expected to get. The main solution is correct and answers test cases,
+// other solutions are known to be wrong or slow.
+type Solution struct {
+	Id         int64       `json:"id"`
+	ProblemId  int64       `json:"-"                xorm:"unique(solution)"`
+	Name       string      `json:"name"             xorm:"unique(solution)"`
+	Lang       string      `json:"language"         xorm:"-"` // source code literal language
+	Language   Language    `json:"-"`                         // source code language
+	Source     string      `json:"source,omitempty" xorm:"-"` // source code, kept as SourceHash
+	SourceHash string      `json:"-"`                         // SHA-256 digest of source code
+	Expect     string      `json:"expected"         xorm:"-"` // literal of Expected
+	Expected   JudgeResult `json:"-"`                         // verdict the solution is expected to get
+	Main       bool        `json:"main"`                      // the correct solution answering test cases
+	CodeId     int64       `json:"codeId"`                    // code of the latest judge of the solution
+	Status     string      `json:"status"           xorm:"-"` // verdict of CodeId
+}
+
+// Init checks the name, language and expected verdict of a solution, a
+// main solution is expected to be accepted.
+func (s *Solution) Init() error {
+	if s.Name == "" {
+		return fmt.Errorf("solution has no name")
+	}
+	var err error
+	if s.Language, err = ParseLanguage(s.Lang); err != nil {
+		return err
+	}
+	if s.Expect == "" && s.Main {
+		s.Expect = Accept.String()
+	}
+	if s.Expected, err = ParseJudgeResult(s.Expect); err != nil {
+		return err
+	}
+	if s.Main && s.Expected != Accept {
+		return fmt.Errorf("main solution %s is expected %s", s.Name, s.Expect)
+	}
+	return nil
+}
+
+func (s Solution) SourcePath() string {
+	return BlobPath("codes", s.SourceHash)
+}
+
+// Code returns a code of the solution to judge.
+func (s Solution) Code() Code {
+	return Code{
+		ProblemId:  s.ProblemId,
+		SolutionId: s.Id,
+		Language:   s.Language,
+		SourceHash: s.SourceHash,
+	}
+}
+
+// ParseJudgeResult parses a verdict by its name like "WrongAnswer", only
+// verdicts of judged codes are parsed.
+func ParseJudgeResult(s string) (JudgeResult, error) {
+	for r := Accept; r <= SystemError; r++ {
+		if r != Handling && r.String() == s {
+			return r, nil
+		}
+	}
+	return Unhandled, fmt.Errorf("unknown verdict %q", s)
+}
+
+// SolutionStatuses returns the verdicts of the latest judges of solutions by
+// code id.
+func SolutionStatuses(engine *xorm.Engine, solutions []Solution) (map[int64]JudgeResult, error) {
+	var ids []interface{}
+	for _, s := range solutions {
+		ids = append(ids, s.CodeId)
+	}
+	statuses := make(map[int64]JudgeResult)
+	if len(ids) == 0 {
+		return statuses, nil
+	}
+	var codes []Code
+	if err := engine.Cols("id", "status").In("id", ids...).Find(&codes); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		statuses[code.Id] = code.Status
+	}
+	return statuses, nil
+}
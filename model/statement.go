@@ -0,0 +1,37 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Statement is the statement of a problem in a locale, the sections are
+// Markdown with LaTeX math between $ or $$.
+type Statement struct {
+	Id        int64  `json:"-"`
+	ProblemId int64  `json:"-"      xorm:"unique(locale)"`
+	Locale    string `json:"locale" xorm:"unique(locale)"` // like "en" or "zh-CN"
+	Legend    string `json:"legend" xorm:"TEXT"`
+	Input     string `json:"input"  xorm:"TEXT"` // input format
+	Output    string `json:"output" xorm:"TEXT"` // output format
+	Notes     string `json:"notes"  xorm:"TEXT"`
+}
+
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// CanonicalLocale returns locale with the language in lower case and the
+// region in upper case, like "zh-CN".
+func CanonicalLocale(locale string) (string, error) {
+	if !localePattern.MatchString(locale) {
+		return "", fmt.Errorf("invalid locale %q", locale)
+	}
+	parts := strings.Split(locale, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) == 2 {
+			parts[i] = strings.ToUpper(parts[i])
+		}
+	}
+	return strings.Join(parts, "-"), nil
+}
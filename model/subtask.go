@@ -0,0 +1,79 @@
+package model
+
+import (
+	"fmt"
+)
+
+// scoring rules of subtasks
+const (
+	AllOrNothing = "all" // full score only if every case is accepted
+	MinScore     = "min" // score times the lowest case score
+	SumScore     = "sum" // score times the average case score
+)
+
+// DefaultMaxScore is the score of a problem without subtasks, which is
+// all or nothing on all of its test cases.
+const DefaultMaxScore = 100
+
+// Subtask is a group of test cases of a problem worth Score points.
+type Subtask struct {
+	Id        int64   `json:"-"`
+	ProblemId int64   `json:"-"     xorm:"index"`
+	Nth       int     `json:"nth"`               // 1-based order in the problem
+	Score     float64 `json:"score"`             // points of the subtask
+	Rule      string  `json:"rule"`              // scoring rule, "all" by default
+	Cases     []int   `json:"cases" xorm:"TEXT"` // nth of test cases in the subtask
+}
+
+// Check checks the subtask of a problem with n test cases.
+func (s *Subtask) Check(n int) error {
+	switch s.Rule {
+	case "":
+		s.Rule = AllOrNothing
+	case AllOrNothing, MinScore, SumScore:
+	default:
+		return fmt.Errorf("unknown scoring rule %s", s.Rule)
+	}
+	if s.Score < 0 {
+		return fmt.Errorf("negative score %g", s.Score)
+	}
+	if len(s.Cases) == 0 {
+		return fmt.Errorf("subtask without test cases")
+	}
+	for _, nth := range s.Cases {
+		if nth < 1 || nth > n {
+			return fmt.Errorf("test case %d not found", nth)
+		}
+	}
+	return nil
+}
+
+// Grade returns the points of the subtask given the scores in [0, 1] of test
+// cases by nth, a missing case scores 0.
+func (s Subtask) Grade(scores map[int]float64) float64 {
+	if len(s.Cases) == 0 {
+		return 0
+	}
+	var (
+		min = 1.0
+		sum float64
+	)
+	for _, nth := range s.Cases {
+		score := scores[nth]
+		if score < min {
+			min = score
+		}
+		sum += score
+	}
+	switch s.Rule {
+	case MinScore:
+		return s.Score * min
+	case SumScore:
+		return s.Score * sum / float64(len(s.Cases))
+	default:
+		if min < 1 {
+			return 0
+		}
+		return s.Score
+	}
+}
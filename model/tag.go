@@ -0,0 +1,45 @@
+package model
+
+import (
+	"strings"
+)
+
+// Tag is a tag of problems, like "dp" or "graph".
+type Tag struct {
+	Id   int64  `json:"-"`
+	Name string `json:"name" xorm:"unique"`
+}
+
+// ProblemTag tags a problem.
+type ProblemTag struct {
+	Id        int64 `json:"-"`
+	ProblemId int64 `json:"-" xorm:"unique(problem_tag)"`
+	TagId     int64 `json:"-" xorm:"unique(problem_tag) index"`
+}
+
+// TagNames returns names of tags in lower case without spaces around,
+// duplicates and empty names.
+func TagNames(names []string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	return tags
+}
+
+// ProblemSummary is a problem in lists, without its statement.
+type ProblemSummary struct {
+	Id         int64    `json:"id"`
+	Title      string   `json:"title"`
+	Difficulty int      `json:"difficulty"`
+	Solved     int64    `json:"solved"`
+	Source     string   `json:"source"`
+	Author     string   `json:"author"`
+	Tags       []string `json:"tags"`
+}
@@ -0,0 +1,49 @@
+package model
+
+// TestCase is the nth test of a problem, its input and output are content
+// addressed files.
+type TestCase struct {
+	Id         int64  `json:"-"`
+	ProblemId  int64  `json:"-"          xorm:"index"`
+	Nth        int    `json:"nth"`        // 1-based order in the problem
+	InputHash  string `json:"inputHash"`  // SHA-256 digest of input
+	InputSize  int64  `json:"inputSize"`  // size of input in byte
+	OutputHash string `json:"outputHash"` // SHA-256 digest of output
+	OutputSize int64  `json:"outputSize"` // size of output in byte
+}
+
+func (t TestCase) InputPath() string {
+	return BlobPath("problems", t.InputHash)
+}
+
+func (t TestCase) OutputPath() string {
+	return BlobPath("problems", t.OutputHash)
+}
+
+// Set records the digest and size of the file named name, which is either
+// "input" or "output".
+func (t *TestCase) Set(name, digest string, size int64) bool {
+	switch name {
+	case "input":
+		t.InputHash, t.InputSize = digest, size
+	case "output":
+		t.OutputHash, t.OutputSize = digest, size
+	default:
+		return false
+	}
+	return true
+}
+
+// CodeCaseResult is the result of a code on the nth test case.
+type CodeCaseResult struct {
+	Id         int64       `json:"-"`
+	CodeId     int64       `json:"-"       xorm:"index"`
+	Nth        int         `json:"nth"`
+	Status     JudgeResult `json:"-"`
+	Verdict    string      `json:"status"  xorm:"-"`    // literal of Status
+	Time       int64       `json:"time"`                // time used in ms
+	Memory     int64       `json:"memory"`              // memory used in KB
+	Score      float64     `json:"score"`               // score in [0, 1]
+	Message    string      `json:"message" xorm:"TEXT"` // checker message
+	Transcript string      `json:"-"`                   // key of the transcript of an interactive problem
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Upload is a resumable chunked upload of the input or output of a test case.
+// A new test case is added once its input and output are both received.
+type Upload struct {
+	Id        string    `json:"id"        xorm:"pk varchar(36)"`
+	ProblemId int64     `json:"problemId" xorm:"index"`
+	Nth       int       `json:"nth"       validate:"min=1"`   // test case
+	Name      string    `json:"name"      validate:"nonzero"` // "input" or "output"
+	Size      int64     `json:"size"      validate:"min=1"`   // total size in byte
+	Received  int64     `json:"received"`                     // bytes received
+	Hash      string    `json:"hash,omitempty"`               // SHA-256 digest of the received file of a new test case waiting for the other file
+	CreatedAt time.Time `json:"createdAt" xorm:"created"`
+}
+
+// ChunkPath returns the storage path of the chunk starting at offset.
+func (u Upload) ChunkPath(offset int64) string {
+	return fmt.Sprintf("%s%016d", u.ChunkPrefix(), offset)
+}
+
+// ChunkPrefix returns the storage prefix of all chunks.
+func (u Upload) ChunkPrefix() string {
+	return fmt.Sprintf("uploads/%s/", u.Id)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// saveOutputs saves the output files of code for an output-only problem
+// before the code refers to them, the total size is limited by dataQuota
+// like tests.
+func saveOutputs(c *gin.Context, code *model.Code) bool {
+	if err := code.InitOutputs(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	var total int64
+	for _, output := range code.Outputs {
+		total += int64(len(output))
+	}
+	if total > dataQuota {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": ErrQuotaExceeded.Error()})
+		return false
+	}
+	code.OutputHashes = make(map[int]string)
+	for nth, output := range code.Outputs {
+		digest, err := SaveBlob("codes", output)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		code.OutputHashes[nth] = digest
+	}
+	return true
+}
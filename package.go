@@ -0,0 +1,496 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+	"github.com/urfave/cli"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+	"github.com/ggaaooppeenngg/validator"
+)
+
+// packageSlack is the limit of the size of sources and metadata of a
+// package in byte, besides its tests and attachments.
+var packageSlack int64 = 16 << 20
+
+// packageError is a package which can not be imported.
+type packageError struct {
+	message string
+}
+
+func (e *packageError) Error() string {
+	return e.message
+}
+
+func badPackage(format string, args ...interface{}) error {
+	return &packageError{fmt.Sprintf(format, args...)}
+}
+
+// imported is a problem read from a package with the sources of its jury
+// programs and reference solutions, and its test cases already stored.
+type imported struct {
+	Problem     model.Problem
+	Cases       []model.TestCase
+	Solutions   []model.Solution
+	Attachments map[string]string // files of statements in the package, or keys in the storage of an exported problem, by attachment name
+	Report      []string          // features of the package which are not imported as they are
+}
+
+// report reports a feature of the package which is not imported as it is.
+func (imp *imported) report(format string, args ...interface{}) {
+	imp.Report = append(imp.Report, fmt.Sprintf(format, args...))
+}
+
+// packageReaders read problems from packages extracted in a directory by
+// format.
+var packageReaders = map[string]func(dir string) (*imported, error){
+	"polygon": readPolygon,
+	"kattis":  readKattis,
+}
+
+// packageWriters write exported problems as package archives by format.
+var packageWriters = map[string]func(w io.Writer, p *imported) error{
+	"kattis": writeKattis,
+}
+
+// packageRoot returns the directory of a package extracted in dir, which
+// has the file marker. The package may be in a directory of the archive.
+func packageRoot(dir, marker string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+		return dir, nil
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, info := range infos {
+		root := filepath.Join(dir, info.Name())
+		if _, err := os.Stat(filepath.Join(root, marker)); info.IsDir() && err == nil {
+			return root, nil
+		}
+	}
+	return "", badPackage("%s not found", marker)
+}
+
+// packagePath returns the path of the file name, slash separated, in the
+// package at root.
+func packagePath(root, name string) string {
+	return filepath.Join(root, filepath.FromSlash(path.Clean("/" + name)[1:]))
+}
+
+// readPackageFile reads the file name in the package at root.
+func readPackageFile(root, name string) (string, error) {
+	data, err := ioutil.ReadFile(packagePath(root, name))
+	if os.IsNotExist(err) {
+		return "", badPackage("%s not found", name)
+	}
+	return string(data), err
+}
+
+// savePackageFile saves the file name in the package at root as a content
+// addressed file of a problem of at most limit bytes.
+func savePackageFile(root, name string, limit int64) (string, int64, error) {
+	f, err := os.Open(packagePath(root, name))
+	if os.IsNotExist(err) {
+		return "", 0, badPackage("%s not found", name)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	return SaveBlobFrom("problems", f, limit)
+}
+
+// readBlob reads the file key in the storage.
+func readBlob(key string) (string, error) {
+	r, err := GetFile(key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	return string(data), err
+}
+
+// importPackage creates a problem from the package archive r in format.
+func importPackage(format string, r io.Reader) (*imported, error) {
+	read, ok := packageReaders[format]
+	if !ok {
+		return nil, badPackage("unknown package format %q", format)
+	}
+	dir, err := ioutil.TempDir("", "package-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	// files are kept as they are, they are not only tests
+	if _, err := extractFiles(r, dir, dataQuota+attachmentQuota+packageSlack, false); err != nil {
+		if err == errArchiveFormat {
+			err = badPackage("%v", err)
+		}
+		return nil, err
+	}
+	imp, err := read(dir)
+	if err != nil {
+		return nil, err
+	}
+	if imp.Report == nil {
+		imp.Report = []string{}
+	}
+	return imp, saveImported(imp)
+}
+
+// saveImported checks an imported problem and inserts it with its reference
+// solutions and attachments.
+func saveImported(imp *imported) error {
+	problem := &imp.Problem
+	if errs := validator.Validate(*problem); errs != nil {
+		return badPackage("%v", errs)
+	}
+	// samples which are no tests are checked by the main solution
+	var main *model.Solution
+	for i := range imp.Solutions {
+		s := &imp.Solutions[i]
+		if err := s.Init(); err != nil {
+			return badPackage("solution %s: %v", s.Name, err)
+		}
+		var err error
+		if s.SourceHash, err = SaveBlob("codes", s.Source); err != nil {
+			return err
+		}
+		s.Source = ""
+		if s.Main {
+			main = s
+		}
+	}
+	for _, check := range []func() error{
+		problem.CheckCompare,
+		problem.CheckType,
+		problem.CheckGenerators,
+		func() error { return checkSubtasks(problem.Subtasks, len(imp.Cases)) },
+		func() error { return checkSamples(problem.Samples, imp.Cases, main) },
+		func() error { return checkStatements(problem.Statements) },
+	} {
+		if err := check(); err != nil {
+			return badPackage("%v", err)
+		}
+	}
+	if err := saveJury(problem); err != nil {
+		return err
+	}
+	invalid, err := validateCases(*problem, imp.Cases)
+	if err != nil {
+		return err
+	}
+	if len(invalid) > 0 {
+		return badPackage("invalid tests: %v", invalid)
+	}
+	// attachments are inserted with the problem, so a failing one leaves
+	// no problem behind
+	return insertProblem(problem, imp.Cases, imp.Solutions, func(s *xorm.Session) error {
+		return importAttachments(s, imp)
+	})
+}
+
+// importAttachments saves the attachments of an imported problem in session
+// s, those over the quota of attachments are reported and skipped.
+func importAttachments(s *xorm.Session, imp *imported) error {
+	var names []string
+	for name := range imp.Attachments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var total int64
+	for _, name := range names {
+		size, err := importAttachment(s, imp.Problem.Id, name, imp.Attachments[name], attachmentQuota-total)
+		if err == ErrQuotaExceeded {
+			imp.report("attachment %s exceeds the quota of attachments", name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		total += size
+	}
+	return nil
+}
+
+// importAttachment saves the file of a package as the attachment name of
+// the new problem id in session s, which is at most limit bytes.
+func importAttachment(s *xorm.Session, problemId int64, name, file string, limit int64) (int64, error) {
+	if limit > attachmentLimit {
+		limit = attachmentLimit
+	}
+	r, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	f, digest, size, err := spool(r, limit)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	attachment := model.Attachment{ProblemId: problemId, Name: name, Size: size, Hash: digest}
+	if err := storeAttachment(&attachment, f); err != nil {
+		return 0, err
+	}
+	_, err = s.InsertOne(&attachment)
+	return size, err
+}
+
+// importStatus returns the status code of an error of importPackage.
+func importStatus(err error) int {
+	if _, ok := err.(*packageError); ok {
+		return http.StatusBadRequest
+	}
+	return answerStatus(err)
+}
+
+// importHandler creates a problem from the package in the body whose format
+// is in the query, and reports what is not imported as it is.
+func importHandler(c *gin.Context) {
+	imp, err := importPackage(c.Query("format"), c.Request.Body)
+	if err != nil {
+		c.JSON(importStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": imp.Problem.Id, "report": imp.Report})
+}
+
+var importCommand = cli.Command{
+	Name:      "import",
+	Usage:     "create problems from packages of other judges",
+	ArgsUsage: "package...",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format",
+			Value: "polygon",
+			Usage: "format of the packages, polygon or kattis",
+		},
+	},
+	Action: importPackages,
+}
+
+func importPackages(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("no package given")
+	}
+	openDB()
+	var err error
+	if store, err = storage.FromEnv(); err != nil {
+		return err
+	}
+	if err := loadQuota(); err != nil {
+		return err
+	}
+	for _, name := range c.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		imp, err := importPackage(c.String("format"), f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		fmt.Printf("%s\tproblem %d\n", name, imp.Problem.Id)
+		for _, r := range imp.Report {
+			fmt.Printf("%s\t%s\n", name, strings.Replace(r, "\n", " ", -1))
+		}
+	}
+	return nil
+}
+
+// exportProblem loads problem with everything a package keeps, the sources
+// of its jury programs and reference solutions are read and its attachments
+// are keys in the storage.
+func exportProblem(problem model.Problem) (*imported, error) {
+	exp := &imported{Problem: problem, Attachments: make(map[string]string)}
+	p := &exp.Problem
+	var err error
+	if exp.Cases, err = casesOf(problem.Id); err != nil {
+		return nil, err
+	}
+	// a problem made before test cases has a single test
+	if len(exp.Cases) == 0 && problem.InputHash != "" {
+		exp.Cases = []model.TestCase{{
+			Nth:        1,
+			InputHash:  problem.InputHash,
+			InputSize:  problem.InputSize,
+			OutputHash: problem.OutputHash,
+			OutputSize: problem.OutputSize,
+		}}
+	}
+	if p.Subtasks, err = subtasksOf(problem.Id); err != nil {
+		return nil, err
+	}
+	if p.Samples, err = samplesOf(problem); err != nil {
+		return nil, err
+	}
+	if p.Statements, err = statementsOf(problem.Id); err != nil {
+		return nil, err
+	}
+	tags, err := tagsOf(problem.Id)
+	if err != nil {
+		return nil, err
+	}
+	p.Tags = tags[problem.Id]
+	for _, j := range []struct {
+		hash, key string
+		dst       *string
+	}{
+		{p.CheckerHash, p.CheckerPath(), &p.Checker},
+		{p.InteractorHash, p.InteractorPath(), &p.Interactor},
+		{p.ValidatorHash, p.ValidatorPath(), &p.Validator},
+	} {
+		if j.hash == "" {
+			continue
+		}
+		if *j.dst, err = readBlob(j.key); err != nil {
+			return nil, err
+		}
+	}
+	for i := range p.Generators {
+		if p.Generators[i].Source, err = readBlob(p.Generators[i].Path()); err != nil {
+			return nil, err
+		}
+	}
+	if exp.Solutions, err = solutionsOf(problem.Id); err != nil {
+		return nil, err
+	}
+	for i := range exp.Solutions {
+		if exp.Solutions[i].Source, err = readBlob(exp.Solutions[i].SourcePath()); err != nil {
+			return nil, err
+		}
+	}
+	attachments, err := attachmentsOf(problem.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attachments {
+		exp.Attachments[a.Name] = a.Path()
+	}
+	return exp, nil
+}
+
+// packageName returns the file name of the package of problem in format.
+func packageName(problem model.Problem, format string) string {
+	return fmt.Sprintf("problem-%d-%s.zip", problem.Id, format)
+}
+
+// exportHandler downloads a problem as a package archive in the format of
+// the query.
+func exportHandler(c *gin.Context) {
+	format := c.Query("format")
+	write, ok := packageWriters[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown package format %q", format)})
+		return
+	}
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	exp, err := exportProblem(problem)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// written to a file first, an error is still reported as JSON
+	f, err := ioutil.TempFile("", "export-")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	err = write(f, exp)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	header := c.Writer.Header()
+	header.Set("Content-Type", "application/zip")
+	header.Set("Content-Disposition", `attachment; filename="`+packageName(problem, format)+`"`)
+	c.Writer.WriteHeader(http.StatusOK)
+	io.Copy(c.Writer, f)
+}
+
+var exportCommand = cli.Command{
+	Name:      "export",
+	Usage:     "write problems as packages of other judges",
+	ArgsUsage: "problem-id...",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format",
+			Value: "kattis",
+			Usage: "format of the packages, kattis",
+		},
+		cli.StringFlag{
+			Name:  "output",
+			Value: ".",
+			Usage: "directory the packages are written to",
+		},
+	},
+	Action: exportPackages,
+}
+
+func exportPackages(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("no problem given")
+	}
+	format := c.String("format")
+	write, ok := packageWriters[format]
+	if !ok {
+		return fmt.Errorf("unknown package format %q", format)
+	}
+	openDB()
+	var err error
+	if store, err = storage.FromEnv(); err != nil {
+		return err
+	}
+	for _, id := range c.Args() {
+		var problem model.Problem
+		has, err := engine.Id(id).Get(&problem)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return fmt.Errorf("problem %s not found", id)
+		}
+		exp, err := exportProblem(problem)
+		if err != nil {
+			return fmt.Errorf("problem %s: %v", id, err)
+		}
+		name := filepath.Join(c.String("output"), packageName(problem, format))
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		err = write(f, exp)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("problem %s: %v", id, err)
+		}
+		fmt.Println(name)
+	}
+	return nil
+}
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// polygonProblem is problem.xml of a Polygon package.
+type polygonProblem struct {
+	ShortName string `xml:"short-name,attr"`
+	Names     []struct {
+		Language string `xml:"language,attr"`
+		Value    string `xml:"value,attr"`
+	} `xml:"names>name"`
+	Statements []struct {
+		Language string `xml:"language,attr"`
+		Type     string `xml:"type,attr"`
+	} `xml:"statements>statement"`
+	Judging struct {
+		InputFile  string           `xml:"input-file,attr"`
+		OutputFile string           `xml:"output-file,attr"`
+		RunCount   int              `xml:"run-count,attr"`
+		Testsets   []polygonTestset `xml:"testset"`
+	} `xml:"judging"`
+	Executables []polygonSource `xml:"files>executables>executable>source"`
+	Checker     *struct {
+		Name   string        `xml:"name,attr"`
+		Type   string        `xml:"type,attr"`
+		Source polygonSource `xml:"source"`
+	} `xml:"assets>checker"`
+	Interactor *struct {
+		Source polygonSource `xml:"source"`
+	} `xml:"assets>interactor"`
+	Validators []polygonSource `xml:"assets>validators>validator>source"`
+	Solutions  []struct {
+		Tag    string        `xml:"tag,attr"`
+		Source polygonSource `xml:"source"`
+	} `xml:"assets>solutions>solution"`
+	Tags []struct {
+		Value string `xml:"value,attr"`
+	} `xml:"tags>tag"`
+}
+
+// polygonSource is a source file of a Polygon package, Type is the
+// language like "cpp.g++17".
+type polygonSource struct {
+	Path string `xml:"path,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// polygonTestset is a set of tests of a Polygon problem with its limits.
+type polygonTestset struct {
+	Name          string `xml:"name,attr"`
+	TimeLimit     int64  `xml:"time-limit"`   // in ms
+	MemoryLimit   int64  `xml:"memory-limit"` // in byte
+	TestCount     int    `xml:"test-count"`
+	InputPattern  string `xml:"input-path-pattern"`
+	AnswerPattern string `xml:"answer-path-pattern"`
+	Tests         []struct {
+		Method string  `xml:"method,attr"`
+		Cmd    string  `xml:"cmd,attr"`
+		Sample bool    `xml:"sample,attr"`
+		Points float64 `xml:"points,attr"`
+		Group  string  `xml:"group,attr"`
+	} `xml:"tests>test"`
+	Groups []struct {
+		Name         string  `xml:"name,attr"`
+		Points       float64 `xml:"points,attr"`
+		PointsPolicy string  `xml:"points-policy,attr"`
+		Dependencies []struct {
+			Group string `xml:"group,attr"`
+		} `xml:"dependencies>dependency"`
+	} `xml:"groups>group"`
+}
+
+// polygonLocales are the locales of languages of Polygon statements.
+var polygonLocales = map[string]string{
+	"arabic":     "ar",
+	"chinese":    "zh",
+	"english":    "en",
+	"french":     "fr",
+	"german":     "de",
+	"italian":    "it",
+	"japanese":   "ja",
+	"kazakh":     "kk",
+	"korean":     "ko",
+	"persian":    "fa",
+	"polish":     "pl",
+	"portuguese": "pt",
+	"russian":    "ru",
+	"spanish":    "es",
+	"turkish":    "tr",
+	"ukrainian":  "uk",
+	"vietnamese": "vi",
+}
+
+// polygonVerdicts are the expected verdicts of solutions by their Polygon
+// tags, tags like "rejected" allowing more than one verdict are missing.
+var polygonVerdicts = map[string]model.JudgeResult{
+	"main":                  model.Accept,
+	"accepted":              model.Accept,
+	"wrong-answer":          model.WrongAnswer,
+	"presentation-error":    model.PresentationError,
+	"time-limit-exceeded":   model.TimeLimitExceeded,
+	"memory-limit-exceeded": model.MemoryLimitExceeded,
+	"failed":                model.RuntimeError,
+}
+
+// polygonLanguage returns the language literal of a Polygon source type, or
+// "" for languages which are not supported.
+func polygonLanguage(typ string) string {
+	switch {
+	case strings.HasPrefix(typ, "cpp."):
+		return "cpp"
+	case strings.HasPrefix(typ, "c."):
+		return "c"
+	case typ == "go" || strings.HasPrefix(typ, "go."):
+		return "go"
+	}
+	return ""
+}
+
+// texMarkup converts common LaTeX markup of Polygon statements to Markdown,
+// math is kept as it is.
+var texMarkup = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`\\textbf\{([^{}]*)\}`), "**$1**"},
+	{regexp.MustCompile(`\\(?:textit|emph)\{([^{}]*)\}`), "*$1*"},
+	{regexp.MustCompile(`\\texttt\{([^{}]*)\}`), "`$1`"},
+	{regexp.MustCompile(`\\(?:sub)*section\*?\{([^{}]*)\}`), "### $1"},
+	{regexp.MustCompile(`\\includegraphics(?:\[[^\]]*\])?\{([^{}]*)\}`), "![](attachment:$1)"},
+	{regexp.MustCompile(`\\(?:begin|end)\{(?:itemize|enumerate|center)\}[ \t]*\n?`), ""},
+	{regexp.MustCompile(`(?m)^[ \t]*\\item[ \t]*`), "- "},
+	{regexp.MustCompile("``|''"), `"`},
+}
+
+// texToMarkdown converts a Polygon statement section in LaTeX to Markdown.
+func texToMarkdown(tex string) string {
+	for _, m := range texMarkup {
+		tex = m.re.ReplaceAllString(tex, m.repl)
+	}
+	return strings.TrimSpace(tex)
+}
+
+// readPolygon reads a Polygon package extracted in dir, a full package with
+// generated tests and their answers, or a package whose answers are the
+// outputs of its main solution.
+func readPolygon(dir string) (*imported, error) {
+	root, err := packageRoot(dir, "problem.xml")
+	if err != nil {
+		return nil, err
+	}
+	data, err := readPackageFile(root, "problem.xml")
+	if err != nil {
+		return nil, err
+	}
+	var p polygonProblem
+	if err := xml.Unmarshal([]byte(data), &p); err != nil {
+		return nil, badPackage("problem.xml: %v", err)
+	}
+	imp := &imported{Attachments: make(map[string]string)}
+	problem := &imp.Problem
+	problem.Title = p.ShortName
+	for _, name := range p.Names {
+		if problem.Title == p.ShortName || name.Language == "english" {
+			problem.Title = name.Value
+		}
+	}
+	for _, tag := range p.Tags {
+		problem.Tags = append(problem.Tags, tag.Value)
+	}
+	if p.Judging.InputFile != "" || p.Judging.OutputFile != "" {
+		imp.report("files %q and %q are standard input and output", p.Judging.InputFile, p.Judging.OutputFile)
+	}
+	if p.Judging.RunCount > 1 {
+		imp.report("solutions are run once instead of %d times", p.Judging.RunCount)
+	}
+
+	type juryFile struct {
+		name   string
+		source polygonSource
+		dst    *string
+	}
+	var jury []juryFile
+	if p.Checker != nil {
+		jury = append(jury, juryFile{"checker", p.Checker.Source, &problem.Checker})
+	}
+	if p.Interactor != nil {
+		problem.Type = model.Interactive
+		jury = append(jury, juryFile{"interactor", p.Interactor.Source, &problem.Interactor})
+	}
+	for i, v := range p.Validators {
+		if i > 0 {
+			imp.report("validator %s is not imported, a problem has one validator", v.Path)
+			continue
+		}
+		jury = append(jury, juryFile{"validator", v, &problem.Validator})
+	}
+	for _, j := range jury {
+		if polygonLanguage(j.source.Type) != "cpp" {
+			imp.report("%s %s is not C++, it is not imported", j.name, j.source.Path)
+			continue
+		}
+		if *j.dst, err = readPackageFile(root, j.source.Path); err != nil {
+			return nil, err
+		}
+	}
+	// executables are generators besides the jury programs
+	generators := make(map[string]bool)
+	for _, j := range jury {
+		generators[j.source.Path] = true
+	}
+	for _, e := range p.Executables {
+		base := path.Base(e.Path)
+		name := strings.TrimSuffix(base, path.Ext(base))
+		if polygonLanguage(e.Type) != "cpp" || name == "" || generators[e.Path] || generators[name] {
+			continue
+		}
+		source, err := readPackageFile(root, e.Path)
+		if err != nil {
+			return nil, err
+		}
+		generators[name] = true
+		problem.Generators = append(problem.Generators, model.Generator{Name: name, Source: source})
+	}
+
+	samples, err := readPolygonTests(imp, root, p)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range p.Solutions {
+		name := path.Base(s.Source.Path)
+		expected, ok := polygonVerdicts[s.Tag]
+		if !ok {
+			imp.report("solution %s tagged %s has no expected verdict, it is not imported", name, s.Tag)
+			continue
+		}
+		lang := polygonLanguage(s.Source.Type)
+		if lang == "" {
+			imp.report("solution %s in %s is not imported", name, s.Source.Type)
+			continue
+		}
+		source, err := readPackageFile(root, s.Source.Path)
+		if err != nil {
+			return nil, err
+		}
+		imp.Solutions = append(imp.Solutions, model.Solution{
+			Name:   name,
+			Lang:   lang,
+			Source: source,
+			Expect: expected.String(),
+			Main:   s.Tag == "main",
+		})
+	}
+	if err := answerPolygon(imp, root); err != nil {
+		return nil, err
+	}
+	if err := readSamples(imp, samples); err != nil {
+		return nil, err
+	}
+	if err := readPolygonStatements(imp, root, p); err != nil {
+		return nil, err
+	}
+	problem.Description = problem.Title
+	if len(problem.Statements) > 0 && problem.Statements[0].Legend != "" {
+		problem.Description = problem.Statements[0].Legend
+	}
+	return imp, nil
+}
+
+// readPolygonTests reads the limits, tests, subtasks and script of the
+// testset "tests" of a Polygon package, answers which are missing are left
+// empty. It returns the nth of tests which are samples.
+func readPolygonTests(imp *imported, root string, p polygonProblem) ([]int, error) {
+	problem := &imp.Problem
+	var testset *polygonTestset
+	for i := range p.Judging.Testsets {
+		if p.Judging.Testsets[i].Name == "tests" {
+			testset = &p.Judging.Testsets[i]
+		} else {
+			imp.report("testset %s is not imported", p.Judging.Testsets[i].Name)
+		}
+	}
+	if testset == nil {
+		return nil, badPackage("testset tests not found")
+	}
+	problem.TimeLimit, problem.MemoryLimit = testset.TimeLimit, testset.MemoryLimit
+	n := len(testset.Tests)
+	if n == 0 {
+		n = testset.TestCount
+	}
+	var (
+		total   int64
+		script  []string
+		manual  []int
+		samples []int
+	)
+	for nth := 1; nth <= n; nth++ {
+		t := model.TestCase{Nth: nth}
+		input := fmt.Sprintf(testset.InputPattern, nth)
+		digest, size, err := savePackageFile(root, input, dataQuota-total)
+		if _, ok := err.(*packageError); ok {
+			return nil, badPackage("input %s of test %d not found, generated tests are in full packages", input, nth)
+		}
+		if err != nil {
+			return nil, err
+		}
+		total += size
+		t.Set("input", digest, size)
+		answer := fmt.Sprintf(testset.AnswerPattern, nth)
+		digest, size, err = savePackageFile(root, answer, dataQuota-total)
+		if _, ok := err.(*packageError); !ok && err != nil {
+			return nil, err
+		}
+		if err == nil {
+			total += size
+			t.Set("output", digest, size)
+		}
+		imp.Cases = append(imp.Cases, t)
+		if nth <= len(testset.Tests) && testset.Tests[nth-1].Sample {
+			samples = append(samples, nth)
+		}
+		if nth <= len(testset.Tests) && testset.Tests[nth-1].Method == "generated" {
+			script = append(script, testset.Tests[nth-1].Cmd)
+		} else {
+			manual = append(manual, nth)
+		}
+	}
+	// a script generates all tests
+	switch {
+	case len(script) == 0:
+	case len(manual) > 0:
+		imp.report("script is not imported, tests %v are not generated", manual)
+	default:
+		problem.Script = strings.Join(script, "\n") + "\n"
+		if _, err := problem.ParseScript(); err != nil {
+			imp.report("script is not imported: %v", err)
+			problem.Script = ""
+		}
+	}
+	readPolygonGroups(imp, testset)
+	return samples, nil
+}
+
+// readPolygonGroups makes subtasks of groups of tests of testset, or of
+// tests with points if there are no groups.
+func readPolygonGroups(imp *imported, testset *polygonTestset) {
+	problem := &imp.Problem
+	if len(testset.Groups) == 0 {
+		for i, t := range testset.Tests {
+			if t.Points > 0 {
+				problem.Subtasks = append(problem.Subtasks, model.Subtask{
+					Score: t.Points,
+					Rule:  model.AllOrNothing,
+					Cases: []int{i + 1},
+				})
+			}
+		}
+		return
+	}
+	grouped := make(map[string][]int)
+	for i, t := range testset.Tests {
+		if t.Group == "" {
+			imp.report("test %d is in no group, it scores nothing", i+1)
+			continue
+		}
+		grouped[t.Group] = append(grouped[t.Group], i+1)
+	}
+	for _, g := range testset.Groups {
+		cases := grouped[g.Name]
+		if len(cases) == 0 {
+			continue
+		}
+		var points []float64
+		var sum float64
+		for _, nth := range cases {
+			points = append(points, testset.Tests[nth-1].Points)
+			sum += testset.Tests[nth-1].Points
+		}
+		subtask := model.Subtask{Score: sum, Rule: model.AllOrNothing, Cases: cases}
+		if g.Points > 0 {
+			subtask.Score = g.Points
+		}
+		if g.PointsPolicy == "each-test" {
+			subtask.Rule = model.SumScore
+			for _, p := range points {
+				if p != points[0] {
+					imp.report("tests of group %s score %v, they score the average of the group", g.Name, points)
+					break
+				}
+			}
+		}
+		if len(g.Dependencies) > 0 {
+			imp.report("dependencies of group %s are not imported", g.Name)
+		}
+		problem.Subtasks = append(problem.Subtasks, subtask)
+	}
+}
+
+// answerPolygon answers the tests without answers by the main solution.
+func answerPolygon(imp *imported, root string) error {
+	var (
+		missing []model.TestCase
+		nths    []int
+		total   int64
+	)
+	for _, t := range imp.Cases {
+		total += t.InputSize + t.OutputSize
+		if t.OutputHash == "" {
+			missing = append(missing, t)
+			nths = append(nths, t.Nth)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	var main *model.Solution
+	for i := range imp.Solutions {
+		if imp.Solutions[i].Main {
+			main = &imp.Solutions[i]
+		}
+	}
+	if main == nil {
+		return badPackage("tests %v have no answers and there is no main solution", nths)
+	}
+	// the solution of a package is untrusted like those of problems
+	dir, err := confine.Contestant.TempDir("answer-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, main.Name)
+	binary := filepath.Join(dir, "main")
+	if err := ioutil.WriteFile(source, []byte(main.Source), 0644); err != nil {
+		return err
+	}
+	language, err := model.ParseLanguage(main.Lang)
+	if err != nil {
+		return err
+	}
+	if err := compileSolution(language, source, binary); err != nil {
+		return &solutionError{0, err}
+	}
+	if err := answerCases(binary, dir, missing, dataQuota-total); err != nil {
+		return err
+	}
+	for _, t := range missing {
+		imp.Cases[t.Nth-1] = t
+	}
+	imp.report("answers of tests %v are outputs of the main solution", nths)
+	return nil
+}
+
+// readSamples makes samples of the test cases nth of an imported problem.
+func readSamples(imp *imported, nths []int) error {
+	for _, nth := range nths {
+		t := imp.Cases[nth-1]
+		input, err := readBlob(t.InputPath())
+		if err != nil {
+			return err
+		}
+		output, err := readBlob(t.OutputPath())
+		if err != nil {
+			return err
+		}
+		imp.Problem.Samples = append(imp.Problem.Samples, model.Sample{Input: input, Output: output})
+	}
+	return nil
+}
+
+// readPolygonStatements reads the statements of a Polygon package from the
+// sections of statements in LaTeX, files besides sections are attachments.
+func readPolygonStatements(imp *imported, root string, p polygonProblem) error {
+	seen := make(map[string]bool)
+	for _, s := range p.Statements {
+		if seen[s.Language] {
+			continue
+		}
+		seen[s.Language] = true
+		locale, ok := polygonLocales[s.Language]
+		if !ok {
+			imp.report("statement in %s is not imported", s.Language)
+			continue
+		}
+		dir := path.Join("statement-sections", s.Language)
+		infos, err := ioutil.ReadDir(packagePath(root, dir))
+		if err != nil {
+			imp.report("statement in %s has no sections, it is not imported", s.Language)
+			continue
+		}
+		sections := make(map[string]string)
+		for _, info := range infos {
+			name := info.Name()
+			switch {
+			case info.IsDir() || strings.HasPrefix(name, "example."):
+			case path.Ext(name) == ".tex":
+				content, err := readPackageFile(root, path.Join(dir, name))
+				if err != nil {
+					return err
+				}
+				sections[strings.TrimSuffix(name, ".tex")] = texToMarkdown(content)
+			case model.CheckAttachmentName(name) == nil:
+				if _, ok := imp.Attachments[name]; !ok {
+					imp.Attachments[name] = packagePath(root, path.Join(dir, name))
+				}
+			default:
+				imp.report("file %s of statements is not imported", path.Join(dir, name))
+			}
+		}
+		statement := model.Statement{
+			Locale: locale,
+			Legend: sections["legend"],
+			Input:  sections["input"],
+			Output: sections["output"],
+			Notes:  sections["notes"],
+		}
+		for _, extra := range []struct{ section, heading string }{
+			{"interaction", "Interaction"},
+			{"scoring", "Scoring"},
+		} {
+			if content := sections[extra.section]; content != "" {
+				statement.Notes = strings.TrimSpace(statement.Notes + "\n\n### " + extra.heading + "\n\n" + content)
+			}
+		}
+		if sections["tutorial"] != "" {
+			imp.report("tutorial in %s is not imported", s.Language)
+		}
+		imp.Problem.Statements = append(imp.Problem.Statements, statement)
+	}
+	if len(imp.Problem.Statements) > 0 {
+		imp.report("statements are converted from LaTeX, check their formatting")
+	}
+	return nil
+}
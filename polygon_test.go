@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+const polygonXML = `<?xml version="1.0" encoding="utf-8" standalone="no"?>
+<problem revision="7" short-name="a-plus-b">
+    <names>
+        <name language="russian" value="A+B по-русски"/>
+        <name language="english" value="A+B"/>
+    </names>
+    <statements>
+        <statement charset="UTF-8" language="english" path="statements/english/problem.tex" type="application/x-tex"/>
+        <statement charset="UTF-8" language="english" path="statements/.html/english/problem.html" type="text/html"/>
+        <statement charset="UTF-8" language="klingon" path="statements/klingon/problem.tex" type="application/x-tex"/>
+    </statements>
+    <judging cpu-name="Intel" input-file="" output-file="" run-count="2">
+        <testset name="tests">
+            <time-limit>2000</time-limit>
+            <memory-limit>268435456</memory-limit>
+            <test-count>3</test-count>
+            <input-path-pattern>tests/%02d</input-path-pattern>
+            <answer-path-pattern>tests/%02d.a</answer-path-pattern>
+            <tests>
+                <test method="manual" sample="true" group="0"/>
+                <test cmd="gen 1" method="generated" points="30" group="1"/>
+                <test cmd="gen 2" method="generated" points="30" group="1"/>
+            </tests>
+            <groups>
+                <group name="0" points-policy="complete-group"/>
+                <group name="1" points="60" points-policy="complete-group">
+                    <dependencies><dependency group="0"/></dependencies>
+                </group>
+            </groups>
+        </testset>
+        <testset name="pretests">
+            <time-limit>2000</time-limit>
+            <memory-limit>268435456</memory-limit>
+            <test-count>0</test-count>
+        </testset>
+    </judging>
+    <files>
+        <resources><file path="files/testlib.h" type="h.g++"/></resources>
+        <executables>
+            <executable><source path="files/gen.cpp" type="cpp.g++17"/></executable>
+            <executable><source path="files/val.cpp" type="cpp.g++17"/></executable>
+        </executables>
+    </files>
+    <assets>
+        <checker name="std::ncmp.cpp" type="testlib"><source path="files/check.cpp" type="cpp.g++17"/></checker>
+        <validators><validator><source path="files/val.cpp" type="cpp.g++17"/></validator></validators>
+        <solutions>
+            <solution tag="main"><source path="solutions/sol.cpp" type="cpp.g++17"/></solution>
+            <solution tag="wrong-answer"><source path="solutions/wa.c" type="c.gcc"/></solution>
+            <solution tag="rejected"><source path="solutions/bad.cpp" type="cpp.g++17"/></solution>
+            <solution tag="accepted"><source path="solutions/sol.java" type="java8"/></solution>
+        </solutions>
+    </assets>
+    <tags><tag value="math"/><tag value="implementation"/></tags>
+</problem>
+`
+
+var polygonFiles = map[string]string{
+	"a-plus-b-7$linux/problem.xml":                              polygonXML,
+	"a-plus-b-7$linux/tests/01":                                 "1 2\n",
+	"a-plus-b-7$linux/tests/01.a":                               "3\n",
+	"a-plus-b-7$linux/tests/02":                                 "10 20\n",
+	"a-plus-b-7$linux/tests/02.a":                               "30\n",
+	"a-plus-b-7$linux/tests/03":                                 "5 5\n",
+	"a-plus-b-7$linux/tests/03.a":                               "10\n",
+	"a-plus-b-7$linux/files/gen.cpp":                            "// gen",
+	"a-plus-b-7$linux/files/val.cpp":                            "// val",
+	"a-plus-b-7$linux/files/check.cpp":                          "// check",
+	"a-plus-b-7$linux/solutions/sol.cpp":                        "// sol",
+	"a-plus-b-7$linux/solutions/wa.c":                           "// wa",
+	"a-plus-b-7$linux/solutions/bad.cpp":                        "// bad",
+	"a-plus-b-7$linux/solutions/sol.java":                       "// java",
+	"a-plus-b-7$linux/statement-sections/english/name.tex":      "A+B",
+	"a-plus-b-7$linux/statement-sections/english/legend.tex":    "Add \\textbf{two} numbers $a$ and $b$.\n\\includegraphics[width=5cm]{sum.png}\n",
+	"a-plus-b-7$linux/statement-sections/english/input.tex":     "\\begin{itemize}\n\\item $a$\n\\item $b$\n\\end{itemize}\n",
+	"a-plus-b-7$linux/statement-sections/english/output.tex":    "The sum ``$a+b$''.",
+	"a-plus-b-7$linux/statement-sections/english/scoring.tex":   "Group 1 is worth 60 points.",
+	"a-plus-b-7$linux/statement-sections/english/example.01":    "1 2\n",
+	"a-plus-b-7$linux/statement-sections/english/sum.png":       "\x89PNG\r\n\x1a\n",
+	"a-plus-b-7$linux/statement-sections/english/tutorial.tex":  "Just add.",
+	"a-plus-b-7$linux/statement-sections/english/bad name!.txt": "",
+}
+
+// extractPolygon extracts a zipped Polygon package of files into a
+// temporary directory.
+func extractPolygon(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "polygon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractFiles(bytes.NewReader(zipArchive(t, files)), dir, 1<<20, false); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestReadPolygon(t *testing.T) {
+	store = storage.NewMemory()
+	dir := extractPolygon(t, polygonFiles)
+	defer os.RemoveAll(dir)
+	imp, err := readPolygon(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problem := imp.Problem
+	if problem.Title != "A+B" || problem.TimeLimit != 2000 || problem.MemoryLimit != 268435456 {
+		t.Errorf("get problem %q with limits %d and %d", problem.Title, problem.TimeLimit, problem.MemoryLimit)
+	}
+	if !reflect.DeepEqual(problem.Tags, []string{"math", "implementation"}) {
+		t.Errorf("get tags %v", problem.Tags)
+	}
+	if problem.Checker != "// check" || problem.Validator != "// val" || problem.Type != "" {
+		t.Errorf("get checker %q, validator %q and type %q", problem.Checker, problem.Validator, problem.Type)
+	}
+	if len(problem.Generators) != 1 || problem.Generators[0].Name != "gen" || problem.Script != "" {
+		t.Errorf("get generators %v and script %q", problem.Generators, problem.Script)
+	}
+
+	if len(imp.Cases) != 3 {
+		t.Fatalf("get %d test cases, want 3", len(imp.Cases))
+	}
+	for i, want := range [][2]string{{"1 2\n", "3\n"}, {"10 20\n", "30\n"}, {"5 5\n", "10\n"}} {
+		c := imp.Cases[i]
+		if c.Nth != i+1 || c.InputHash != storage.Digest([]byte(want[0])) || c.OutputHash != storage.Digest([]byte(want[1])) {
+			t.Errorf("test %d is %+v, want %q and %q", i+1, c, want[0], want[1])
+		}
+	}
+	wantSubtasks := []model.Subtask{
+		{Score: 0, Rule: model.AllOrNothing, Cases: []int{1}},
+		{Score: 60, Rule: model.AllOrNothing, Cases: []int{2, 3}},
+	}
+	if !reflect.DeepEqual(problem.Subtasks, wantSubtasks) {
+		t.Errorf("get subtasks %+v, want %+v", problem.Subtasks, wantSubtasks)
+	}
+	if !reflect.DeepEqual(problem.Samples, []model.Sample{{Input: "1 2\n", Output: "3\n"}}) {
+		t.Errorf("get samples %+v", problem.Samples)
+	}
+
+	wantSolutions := []model.Solution{
+		{Name: "sol.cpp", Lang: "cpp", Source: "// sol", Expect: "Accept", Main: true},
+		{Name: "wa.c", Lang: "c", Source: "// wa", Expect: "WrongAnswer"},
+	}
+	if !reflect.DeepEqual(imp.Solutions, wantSolutions) {
+		t.Errorf("get solutions %+v, want %+v", imp.Solutions, wantSolutions)
+	}
+
+	if len(problem.Statements) != 1 {
+		t.Fatalf("get %d statements, want 1", len(problem.Statements))
+	}
+	statement := problem.Statements[0]
+	wantStatement := model.Statement{
+		Locale: "en",
+		Legend: "Add **two** numbers $a$ and $b$.\n![](attachment:sum.png)",
+		Input:  "- $a$\n- $b$",
+		Output: `The sum "$a+b$".`,
+		Notes:  "### Scoring\n\nGroup 1 is worth 60 points.",
+	}
+	if !reflect.DeepEqual(statement, wantStatement) {
+		t.Errorf("get statement %+v, want %+v", statement, wantStatement)
+	}
+	if problem.Description != wantStatement.Legend {
+		t.Errorf("get description %q", problem.Description)
+	}
+	if len(imp.Attachments) != 1 || imp.Attachments["sum.png"] == "" {
+		t.Errorf("get attachments %v", imp.Attachments)
+	}
+	for _, want := range []string{
+		"solutions are run once instead of 2 times",
+		"testset pretests is not imported",
+		"script is not imported, tests [1] are not generated",
+		"dependencies of group 1 are not imported",
+		"solution bad.cpp tagged rejected has no expected verdict, it is not imported",
+		"solution sol.java in java8 is not imported",
+		"statement in klingon is not imported",
+		"tutorial in english is not imported",
+		"file statement-sections/english/bad name!.txt of statements is not imported",
+	} {
+		found := false
+		for _, r := range imp.Report {
+			found = found || r == want
+		}
+		if !found {
+			t.Errorf("%q is not reported in %q", want, imp.Report)
+		}
+	}
+}
+
+func TestReadPolygonWithoutAnswers(t *testing.T) {
+	store = storage.NewMemory()
+	files := make(map[string]string)
+	for name, content := range polygonFiles {
+		if name != "a-plus-b-7$linux/tests/02.a" {
+			files[name] = content
+		}
+	}
+	files["a-plus-b-7$linux/solutions/sol.cpp"] = "#include <cstdio>\nint main() { int a, b; scanf(\"%d %d\", &a, &b); printf(\"%d\\n\", a + b); }\n"
+	dir := extractPolygon(t, files)
+	defer os.RemoveAll(dir)
+	imp, err := readPolygon(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp.Cases[1].OutputHash != storage.Digest([]byte("30\n")) {
+		t.Errorf("test 2 is not answered by the main solution: %+v", imp.Cases[1])
+	}
+
+	// the main solution runs confined
+	defer func(quota int64) { dataQuota = quota }(dataQuota)
+	dataQuota = 1 << 10
+	files["a-plus-b-7$linux/solutions/sol.cpp"] = "#include <cstdio>\nint main() { for (;;) putchar('0'); }\n"
+	dir = extractPolygon(t, files)
+	defer os.RemoveAll(dir)
+	if _, err := readPolygon(dir); err == nil || importStatus(err) != 400 || !strings.Contains(err.Error(), "writes more than") {
+		t.Errorf("read a package whose main solution writes over the quota: %v", err)
+	}
+
+	// only the main solution answers tests
+	files["a-plus-b-7$linux/problem.xml"] = strings.Replace(polygonXML, `tag="main"`, `tag="accepted"`, 1)
+	dir = extractPolygon(t, files)
+	defer os.RemoveAll(dir)
+	_, err = readPolygon(dir)
+	if err == nil || err.Error() != "tests [2] have no answers and there is no main solution" {
+		t.Errorf("read a package without answers and the main solution: %v", err)
+	}
+
+	delete(files, "a-plus-b-7$linux/tests/02")
+	dir = extractPolygon(t, files)
+	defer os.RemoveAll(dir)
+	if _, err := readPolygon(dir); err == nil || importStatus(err) != 400 {
+		t.Errorf("read a package without generated tests: %v", err)
+	}
+}
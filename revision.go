@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/validator"
+)
+
+// snapshot records the problem as it is in session s as its next revision.
+// A change of tests or limits since the last revision verifies the problem.
+func snapshot(s *xorm.Session, problemId int64) error {
+	var problem model.Problem
+	has, err := s.Id(problemId).Get(&problem)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return fmt.Errorf("problem %d not found", problemId)
+	}
+	var cases []model.TestCase
+	if err := s.Where("problem_id = ?", problemId).Asc("nth").Find(&cases); err != nil {
+		return err
+	}
+	var subtasks []model.Subtask
+	if err := s.Where("problem_id = ?", problemId).Asc("nth").Find(&subtasks); err != nil {
+		return err
+	}
+	var statements []model.Statement
+	if err := s.Where("problem_id = ?", problemId).Asc("locale").Find(&statements); err != nil {
+		return err
+	}
+	var samples []model.Sample
+	if err := s.Where("problem_id = ?", problemId).Asc("nth").Find(&samples); err != nil {
+		return err
+	}
+	var previous model.ProblemRevision
+	has, err = s.Where("problem_id = ? AND revision = ?", problemId, problem.Revision).Get(&previous)
+	if err != nil {
+		return err
+	}
+	problem.Revision++
+	// a concurrent revision of the same number violates the unique index
+	revision := model.NewRevision(problem, cases, subtasks, statements, samples)
+	if _, err := s.InsertOne(&revision); err != nil {
+		return err
+	}
+	// reference solutions are judged again when tests or limits change
+	if !has || !reflect.DeepEqual(judging(previous), judging(revision)) {
+		if err := resubmitSolutions(s, problemId); err != nil {
+			return err
+		}
+	}
+	_, err = s.Id(problemId).Cols("revision").Update(&model.Problem{Revision: problem.Revision})
+	return err
+}
+
+// insertProblem inserts a new problem with its test cases, subtasks,
+// samples, statements, tags and reference solutions as its first revision.
+// more, if not nil, adds to the problem in the same transaction, an error
+// of it inserts nothing.
+func insertProblem(problem *model.Problem, cases []model.TestCase, solutions []model.Solution, more func(s *xorm.Session) error) error {
+	transaction := engine.NewSession()
+	defer transaction.Close()
+	if err := transaction.Begin(); err != nil {
+		return err
+	}
+	insert := func() error {
+		if _, err := transaction.InsertOne(problem); err != nil {
+			return err
+		}
+		if err := replaceCases(transaction, *problem, cases); err != nil {
+			return err
+		}
+		if err := replaceSubtasks(transaction, *problem, problem.Subtasks); err != nil {
+			return err
+		}
+		if err := replaceSamples(transaction, problem.Id, problem.Samples); err != nil {
+			return err
+		}
+		if err := replaceStatements(transaction, problem.Id, problem.Statements); err != nil {
+			return err
+		}
+		if err := setTags(transaction, problem.Id, problem.Tags); err != nil {
+			return err
+		}
+		for i := range solutions {
+			solutions[i].ProblemId = problem.Id
+			if _, err := transaction.InsertOne(&solutions[i]); err != nil {
+				return err
+			}
+		}
+		if more != nil {
+			if err := more(transaction); err != nil {
+				return err
+			}
+		}
+		// the first revision verifies the problem with its solutions
+		return snapshot(transaction, problem.Id)
+	}
+	if err := insert(); err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+// revise makes change to problem id in a transaction, which records the
+// problem after change as its next revision.
+func revise(problemId int64, change func(s *xorm.Session) error) error {
+	transaction := engine.NewSession()
+	defer transaction.Close()
+	if err := transaction.Begin(); err != nil {
+		return err
+	}
+	if err := change(transaction); err != nil {
+		transaction.Rollback()
+		return err
+	}
+	if err := snapshot(transaction, problemId); err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+// getRevision gets the revision rev of problem id.
+func getRevision(c *gin.Context, id interface{}, rev string) (model.ProblemRevision, bool) {
+	var revision model.ProblemRevision
+	has, err := engine.Where("problem_id = ? AND revision = ?", id, rev).Get(&revision)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return revision, false
+	}
+	if !has {
+		c.JSON(http.StatusNotFound, gin.H{"error": "revision not found"})
+		return revision, false
+	}
+	return revision, true
+}
+
+// listRevisions lists the revisions of a problem without their tests.
+func listRevisions(c *gin.Context) {
+	var revisions []model.ProblemRevision
+	err := engine.Where("problem_id = ?", c.Param("id")).Omit("description", "cases", "subtasks").Desc("revision").Find(&revisions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+// getRevisionHandler gets a revision of a problem with its tests.
+func getRevisionHandler(c *gin.Context) {
+	if revision, ok := getRevision(c, c.Param("id"), c.Param("rev")); ok {
+		c.JSON(http.StatusOK, gin.H{"revision": revision})
+	}
+}
+
+// change is a difference between two revisions.
+type change struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// diffRevisions returns the changes from revision a to b, fields first,
+// then test cases, subtasks and samples by nth, then statements by locale.
+func diffRevisions(a, b model.ProblemRevision) []change {
+	var changes []change
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		switch name := t.Field(i).Name; name {
+		case "Id", "ProblemId", "Revision", "CreatedAt", "Cases", "Subtasks", "Statements", "Samples":
+		default:
+			from, to := va.Field(i).Interface(), vb.Field(i).Interface()
+			if !reflect.DeepEqual(from, to) {
+				changes = append(changes, change{name, from, to})
+			}
+		}
+	}
+	changes = append(changes, diffList("cases", a.Cases, b.Cases)...)
+	changes = append(changes, diffList("subtasks", a.Subtasks, b.Subtasks)...)
+	changes = append(changes, diffList("samples", a.Samples, b.Samples)...)
+	statements := make(map[string][2]interface{})
+	var locales []string
+	for i, revision := range []model.ProblemRevision{a, b} {
+		for _, statement := range revision.Statements {
+			statement.Id = 0
+			pair, ok := statements[statement.Locale]
+			if !ok {
+				locales = append(locales, statement.Locale)
+			}
+			pair[i] = statement
+			statements[statement.Locale] = pair
+		}
+	}
+	sort.Strings(locales)
+	for _, locale := range locales {
+		pair := statements[locale]
+		if !reflect.DeepEqual(pair[0], pair[1]) {
+			changes = append(changes, change{"statements." + locale, pair[0], pair[1]})
+		}
+	}
+	return changes
+}
+
+// diffList returns the changes from slice a to slice b of field by nth.
+func diffList(field string, a, b interface{}) []change {
+	var changes []change
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for nth := 1; nth <= va.Len() || nth <= vb.Len(); nth++ {
+		var from, to interface{}
+		if nth <= va.Len() {
+			from = va.Index(nth - 1).Interface()
+		}
+		if nth <= vb.Len() {
+			to = vb.Index(nth - 1).Interface()
+		}
+		if !reflect.DeepEqual(from, to) {
+			changes = append(changes, change{field + "." + strconv.Itoa(nth), from, to})
+		}
+	}
+	return changes
+}
+
+// diff gets the changes of a problem from revision "from" to revision "to"
+// in the query.
+func diff(c *gin.Context) {
+	from, ok := getRevision(c, c.Param("id"), c.Query("from"))
+	if !ok {
+		return
+	}
+	to, ok := getRevision(c, c.Param("id"), c.Query("to"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"changes": diffRevisions(from, to)})
+}
+
+// rollback restores a problem to a revision, which is recorded as a new
+// revision so the history is kept. Tags, attachments and reference
+// solutions are not in revisions, they are kept as they are.
+func rollback(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	revision, ok := getRevision(c, problem.Id, c.Param("rev"))
+	if !ok {
+		return
+	}
+	restored := revision.Problem()
+	err := revise(problem.Id, func(s *xorm.Session) error {
+		if err := replaceCases(s, problem, revision.Cases); err != nil {
+			return err
+		}
+		if err := replaceSubtasks(s, problem, revision.Subtasks); err != nil {
+			return err
+		}
+		if err := replaceStatements(s, problem.Id, revision.Statements); err != nil {
+			return err
+		}
+		if err := replaceSamples(s, problem.Id, revision.Samples); err != nil {
+			return err
+		}
+		_, err := s.Id(problem.Id).Cols(
+			"title", "time_limit", "memory_limit", "description",
+			"type", "compare", "epsilon", "checker_hash", "interactor_hash", "validator_hash", "generators", "script",
+			"input_hash", "output_hash", "input_size", "output_size",
+		).Update(&restored)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	problem, ok = getProblem(c, problem.Id)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revision": problem.Revision})
+}
+
+// putProblem changes the statement and limits of a problem.
+func putProblem(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var req model.Problem
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errs := validator.Validate(req); errs != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errs})
+		return
+	}
+	err := revise(problem.Id, func(s *xorm.Session) error {
+		_, err := s.Id(problem.Id).Cols("title", "time_limit", "memory_limit", "description").Update(&req)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+func TestDiffRevisions(t *testing.T) {
+	a := model.ProblemRevision{
+		Revision:  1,
+		Title:     "a+b",
+		TimeLimit: 1000,
+		Cases:     []model.TestCase{{Nth: 1, InputHash: "i1", OutputHash: "o1"}, {Nth: 2, InputHash: "i2", OutputHash: "o2"}},
+	}
+	b := a
+	b.Revision = 2
+	if changes := diffRevisions(a, b); len(changes) != 0 {
+		t.Errorf("get changes %v of the same problem", changes)
+	}
+	b.TimeLimit = 2000
+	b.Cases = []model.TestCase{{Nth: 1, InputHash: "i1", OutputHash: "o3"}}
+	b.Subtasks = []model.Subtask{{Nth: 1, Score: 100, Rule: model.AllOrNothing, Cases: []int{1}}}
+	want := []change{
+		{"TimeLimit", int64(1000), int64(2000)},
+		{"cases.1", a.Cases[0], b.Cases[0]},
+		{"cases.2", a.Cases[1], nil},
+		{"subtasks.1", nil, b.Subtasks[0]},
+	}
+	if changes := diffRevisions(a, b); !reflect.DeepEqual(changes, want) {
+		t.Errorf("get changes %v, want %v", changes, want)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+// samplesOf returns the samples of problem in order, a problem made before
+// samples has the sample in its InputSample and OutputSample.
+func samplesOf(problem model.Problem) ([]model.Sample, error) {
+	var samples []model.Sample
+	if err := engine.Where("problem_id = ?", problem.Id).Asc("nth").Find(&samples); err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return problem.LegacySamples(), nil
+	}
+	return samples, nil
+}
+
+// replaceSamples replaces the samples of problem id with samples in session s.
+func replaceSamples(s *xorm.Session, problemId int64, samples []model.Sample) error {
+	if _, err := s.Where("problem_id = ?", problemId).Delete(&model.Sample{}); err != nil {
+		return err
+	}
+	for i := range samples {
+		samples[i].Id = 0
+		samples[i].ProblemId = problemId
+		samples[i].Nth = i + 1
+		if _, err := s.InsertOne(&samples[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// digests returns the digests content may have as a test, it may lack the
+// last newline or have Windows line endings.
+func digests(content string) []string {
+	unix := strings.Replace(content, "\r\n", "\n", -1)
+	variants := []string{content, unix}
+	if !strings.HasSuffix(unix, "\n") {
+		variants = append(variants, unix+"\n")
+	}
+	var ds []string
+	for _, v := range variants {
+		ds = append(ds, storage.Digest([]byte(v)))
+	}
+	return ds
+}
+
+// contains reports whether s is in list.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSamples sets Test of samples to the test case of the same input and
+// output. A sample with the input of a test case but another output is
+// wrong, the first wrong sample is returned as an error.
+func matchSamples(samples []model.Sample, cases []model.TestCase) error {
+	var err error
+	inputs := make(map[string]model.TestCase)
+	for _, t := range cases {
+		if _, ok := inputs[t.InputHash]; !ok {
+			inputs[t.InputHash] = t
+		}
+	}
+	for i := range samples {
+		samples[i].Test = 0
+		var test model.TestCase
+		var found bool
+		for _, digest := range digests(samples[i].Input) {
+			if test, found = inputs[digest]; found {
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		for _, digest := range digests(samples[i].Output) {
+			if digest == test.OutputHash {
+				samples[i].Test = test.Nth
+			}
+		}
+		if samples[i].Test == 0 && err == nil {
+			err = fmt.Errorf("sample %d has the input of test %d but another output", i+1, test.Nth)
+		}
+	}
+	return err
+}
+
+// checkSamples checks samples of a problem with test cases and matches
+// them, an empty sample is an error. Samples which are no test cases are
+// checked by running main, the saved main solution, they are wrong without
+// a main solution.
+func checkSamples(samples []model.Sample, cases []model.TestCase, main *model.Solution) error {
+	for i, sample := range samples {
+		if sample.Input == "" && sample.Output == "" {
+			return fmt.Errorf("sample %d is empty", i+1)
+		}
+	}
+	if err := matchSamples(samples, cases); err != nil {
+		return err
+	}
+	var unmatched []int
+	for i := range samples {
+		if samples[i].Test == 0 {
+			unmatched = append(unmatched, i)
+		}
+	}
+	if len(unmatched) == 0 {
+		return nil
+	}
+	if main == nil {
+		return fmt.Errorf("sample %d is no test case and there is no main solution to check it", unmatched[0]+1)
+	}
+	return runSamples(samples, unmatched, *main)
+}
+
+// runSamples runs main confined on the samples nth, a sample is wrong if
+// main outputs anything else.
+func runSamples(samples []model.Sample, nths []int, main model.Solution) error {
+	dir, err := confine.Contestant.TempDir("sample-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	binary, err := buildSolution(main, dir)
+	if err != nil {
+		return err
+	}
+	input, output := filepath.Join(dir, "input"), filepath.Join(dir, "output")
+	for _, i := range nths {
+		if err := ioutil.WriteFile(input, []byte(samples[i].Input), 0644); err != nil {
+			return err
+		}
+		if err := runTo(binary, nil, input, output); err != nil {
+			return fmt.Errorf("main solution on sample %d: %v", i+1, err)
+		}
+		out, err := ioutil.ReadFile(output)
+		if err != nil {
+			return err
+		}
+		if !contains(digests(samples[i].Output), storage.Digest(out)) {
+			return fmt.Errorf("sample %d has another output than the main solution", i+1)
+		}
+	}
+	return nil
+}
+
+// getSamples gets the samples of a problem and the test cases they are.
+func getSamples(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	samples, err := samplesOf(problem)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cases, err := casesOf(problem.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// a sample is wrong only if tests changed after it
+	matchSamples(samples, cases)
+	c.JSON(http.StatusOK, gin.H{"samples": samples})
+}
+
+// putSamples replaces the samples of a problem, which are checked against
+// its test cases and its main solution.
+func putSamples(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var req struct {
+		Samples []model.Sample `json:"samples"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cases, err := casesOf(problem.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var main *model.Solution
+	if solution, err := mainSolution(problem.Id); err == nil {
+		main = &solution
+	} else if err != errNoMainSolution {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkSamples(req.Samples, cases, main); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = revise(problem.Id, func(s *xorm.Session) error {
+		// samples replace InputSample and OutputSample
+		if _, err := s.Id(problem.Id).Cols("input_sample", "output_sample").Update(&model.Problem{}); err != nil {
+			return err
+		}
+		return replaceSamples(s, problem.Id, req.Samples)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Samples == nil {
+		req.Samples = []model.Sample{}
+	}
+	c.JSON(http.StatusOK, gin.H{"samples": req.Samples})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+func TestMatchSamples(t *testing.T) {
+	test := func(nth int, input, output string) model.TestCase {
+		return model.TestCase{Nth: nth, InputHash: storage.Digest([]byte(input)), OutputHash: storage.Digest([]byte(output))}
+	}
+	cases := []model.TestCase{test(1, "1 2\n", "3\n"), test(2, "2 3\n", "5\n")}
+	samples := []model.Sample{
+		{Input: "2 3", Output: "5"},
+		{Input: "1 2\r\n", Output: "3\r\n", Explanation: "1 + 2 = 3"},
+		{Input: "3 4\n", Output: "7\n"},
+	}
+	if err := checkSamples(samples, cases, nil); err == nil || !strings.Contains(err.Error(), "sample 3") {
+		t.Errorf("check a sample which is no test without a main solution, error %v", err)
+	}
+	for i, want := range []int{2, 1, 0} {
+		if samples[i].Test != want {
+			t.Errorf("sample %d is test %d, want %d", i+1, samples[i].Test, want)
+		}
+	}
+	samples[1].Output = "4\n"
+	if err := checkSamples(samples, cases, nil); err == nil || !strings.Contains(err.Error(), "sample 2") {
+		t.Errorf("check a wrong sample with error %v", err)
+	}
+	if samples[0].Test != 2 {
+		t.Errorf("samples after a wrong one are not matched")
+	}
+	if err := checkSamples([]model.Sample{{}}, cases, nil); err == nil {
+		t.Error("check an empty sample without error")
+	}
+}
+
+func TestRunSamples(t *testing.T) {
+	store = storage.NewMemory()
+	digest, err := SaveBlob("codes", "#include <stdio.h>\nint main() { int a, b; scanf(\"%d%d\", &a, &b); printf(\"%d\\n\", a + b); }\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := &model.Solution{Language: model.C, SourceHash: digest}
+	samples := []model.Sample{{Input: "1 2\n", Output: "3\n"}, {Input: "3 4", Output: "7"}}
+	if err := checkSamples(samples, nil, main); err != nil {
+		t.Fatal(err)
+	}
+	samples[1].Output = "8"
+	if err := checkSamples(samples, nil, main); err == nil || !strings.Contains(err.Error(), "sample 2") {
+		t.Errorf("check a sample the main solution answers otherwise with error %v", err)
+	}
+}
+
+func TestSampleJSON(t *testing.T) {
+	problem := model.Problem{Id: 1, InputSample: "1 2", OutputSample: "3"}
+	b, err := json.Marshal(problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["inputSample"] != "1 2" || fields["outputSample"] != "3" {
+		t.Errorf("marshal samples as %v and %v", fields["inputSample"], fields["outputSample"])
+	}
+	b, err = json.Marshal(problem.LegacySamples())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"nth":1,"input":"1 2","output":"3","explanation":"","test":0}]`; string(b) != want {
+		t.Errorf("marshal legacy samples to %s, want %s", b, want)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// maxListLimit is the most problems listed at once.
+const maxListLimit = 100
+
+// setTags replaces the tags of problem id with names in session s, tags are
+// created on first use.
+func setTags(s *xorm.Session, problemId int64, names []string) error {
+	if _, err := s.Where("problem_id = ?", problemId).Delete(&model.ProblemTag{}); err != nil {
+		return err
+	}
+	for _, name := range model.TagNames(names) {
+		tag := model.Tag{Name: name}
+		has, err := s.Where("name = ?", name).Get(&tag)
+		if err != nil {
+			return err
+		}
+		if !has {
+			if _, err := s.InsertOne(&tag); err != nil {
+				return err
+			}
+		}
+		if _, err := s.InsertOne(&model.ProblemTag{ProblemId: problemId, TagId: tag.Id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagsOf returns the names of tags of problems by id.
+func tagsOf(ids ...int64) (map[int64][]string, error) {
+	tags := make(map[int64][]string)
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	var rows []struct {
+		ProblemId int64
+		Name      string
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	err := engine.Sql("SELECT problem_tag.problem_id, tag.name FROM problem_tag INNER JOIN tag ON tag.id = problem_tag.tag_id"+
+		" WHERE problem_tag.problem_id IN ("+placeholders(len(ids))+") ORDER BY tag.name", args...).Find(&rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tags[row.ProblemId] = append(tags[row.ProblemId], row.Name)
+	}
+	return tags, nil
+}
+
+// placeholders returns n comma separated placeholders of arguments.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// problemFilter is the query of GET /problems.
+type problemFilter struct {
+	Tags          []string // problems with all the tags
+	MinDifficulty *int
+	MaxDifficulty *int
+	UserId        int64 // the caller, for Solved
+	Solved        *bool // problems solved by UserId or not
+	Title         string
+	Sort          string // id, solved or difficulty
+	Desc          bool
+	Limit, Start  int
+}
+
+// sortColumns are the columns problems are sorted by.
+var sortColumns = map[string]string{"id": "id", "solved": "solved", "difficulty": "difficulty"}
+
+// parseFilter parses the query of GET /problems.
+func parseFilter(c *gin.Context) (problemFilter, error) {
+	query := c.Request.URL.Query()
+	f := problemFilter{Tags: model.TagNames(query["tag"]), Title: c.Query("title"), Sort: "id", Limit: 20}
+	ints := map[string]*int{"limit": &f.Limit, "start": &f.Start}
+	for _, name := range []string{"minDifficulty", "maxDifficulty"} {
+		if c.Query(name) != "" {
+			ints[name] = new(int)
+		}
+	}
+	for name, v := range ints {
+		if s := c.Query(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return f, fmt.Errorf("%s: %v", name, err)
+			}
+			*v = n
+		}
+	}
+	f.MinDifficulty, f.MaxDifficulty = ints["minDifficulty"], ints["maxDifficulty"]
+	if f.Limit < 1 || f.Limit > maxListLimit {
+		f.Limit = maxListLimit
+	}
+	if s := c.Query("solved"); s != "" {
+		solved, err := strconv.ParseBool(s)
+		if err != nil {
+			return f, fmt.Errorf("solved: %v", err)
+		}
+		f.Solved = &solved
+		if f.UserId, err = strconv.ParseInt(c.Query("userId"), 10, 64); err != nil {
+			return f, fmt.Errorf("solved needs userId")
+		}
+	}
+	if s := c.Query("sort"); s != "" {
+		if _, ok := sortColumns[s]; !ok {
+			return f, fmt.Errorf("unknown sort %s", s)
+		}
+		f.Sort = s
+	}
+	f.Desc = c.Query("order") == "desc"
+	return f, nil
+}
+
+// where returns the condition and arguments of the filter.
+func (f problemFilter) where() (string, []interface{}) {
+	conds := []string{"1 = 1"}
+	var args []interface{}
+	if len(f.Tags) > 0 {
+		conds = append(conds, "id IN (SELECT problem_tag.problem_id FROM problem_tag INNER JOIN tag ON tag.id = problem_tag.tag_id"+
+			" WHERE tag.name IN ("+placeholders(len(f.Tags))+") GROUP BY problem_tag.problem_id HAVING COUNT(*) = ?)")
+		for _, tag := range f.Tags {
+			args = append(args, tag)
+		}
+		args = append(args, len(f.Tags))
+	}
+	if f.MinDifficulty != nil {
+		conds = append(conds, "difficulty >= ?")
+		args = append(args, *f.MinDifficulty)
+	}
+	if f.MaxDifficulty != nil {
+		conds = append(conds, "difficulty <= ?")
+		args = append(args, *f.MaxDifficulty)
+	}
+	if f.Solved != nil {
+		solved := "id IN (SELECT problem_id FROM code WHERE user_id = ? AND status = ? AND solution_id = 0)"
+		if !*f.Solved {
+			solved = "id NOT IN (SELECT problem_id FROM code WHERE user_id = ? AND status = ? AND solution_id = 0)"
+		}
+		conds = append(conds, solved)
+		args = append(args, f.UserId, model.Accept)
+	}
+	if f.Title != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(f.Title)
+		conds = append(conds, "title ILIKE ?")
+		args = append(args, "%"+escaped+"%")
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// listProblems lists summaries of problems filtered by the query.
+func listProblems(c *gin.Context) {
+	f, err := parseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	where, args := f.where()
+	s := engine.Where(where, args...).Cols("id", "title", "difficulty", "solved", "source", "author")
+	column := sortColumns[f.Sort]
+	if f.Desc {
+		s = s.Desc(column)
+	} else {
+		s = s.Asc(column)
+	}
+	if column != "id" {
+		s = s.Asc("id")
+	}
+	var problems []model.Problem
+	if err := s.Limit(f.Limit, f.Start).Find(&problems); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ids := make([]int64, len(problems))
+	for i, p := range problems {
+		ids[i] = p.Id
+	}
+	tags, err := tagsOf(ids...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	summaries := make([]model.ProblemSummary, len(problems))
+	for i, p := range problems {
+		summaries[i] = model.ProblemSummary{
+			Id:         p.Id,
+			Title:      p.Title,
+			Difficulty: p.Difficulty,
+			Solved:     p.Solved,
+			Source:     p.Source,
+			Author:     p.Author,
+			Tags:       tags[p.Id],
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"problems": summaries})
+}
+
+// putMeta sets the difficulty, source, author and tags of a problem, which
+// are not part of its revisions.
+func putMeta(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var req model.Problem
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction := engine.NewSession()
+	defer transaction.Close()
+	if err := transaction.Begin(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := transaction.Id(problem.Id).Cols("difficulty", "source", "author").Update(&req); err != nil {
+		transaction.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := setTags(transaction, problem.Id, req.Tags); err != nil {
+		transaction.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := transaction.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": model.TagNames(req.Tags)})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+func TestProblemFilter(t *testing.T) {
+	req, err := http.NewRequest("GET", "/problems?tag=DP&tag=graph&tag=dp&minDifficulty=3&solved=false&userId=7&title=50%25_off&sort=difficulty&order=desc&limit=1000", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parseFilter(&gin.Context{Request: req})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Limit != maxListLimit || f.Sort != "difficulty" || !f.Desc || f.MaxDifficulty != nil {
+		t.Errorf("parse filter %+v", f)
+	}
+	where, args := f.where()
+	wantWhere := "1 = 1" +
+		" AND id IN (SELECT problem_tag.problem_id FROM problem_tag INNER JOIN tag ON tag.id = problem_tag.tag_id" +
+		" WHERE tag.name IN (?, ?) GROUP BY problem_tag.problem_id HAVING COUNT(*) = ?)" +
+		" AND difficulty >= ?" +
+		" AND id NOT IN (SELECT problem_id FROM code WHERE user_id = ? AND status = ? AND solution_id = 0)" +
+		" AND title ILIKE ?"
+	wantArgs := []interface{}{"dp", "graph", 2, 3, int64(7), model.Accept, `%50\%\_off%`}
+	if where != wantWhere {
+		t.Errorf("get where %q, want %q", where, wantWhere)
+	}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("get args %v, want %v", args, wantArgs)
+	}
+
+	for _, query := range []string{"solved=true", "sort=title", "minDifficulty=hard"} {
+		req, _ := http.NewRequest("GET", "/problems?"+query, nil)
+		if _, err := parseFilter(&gin.Context{Request: req}); err == nil {
+			t.Errorf("parse filter %s without error", query)
+		}
+	}
+}
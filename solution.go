@@ -0,0 +1,345 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+var (
+	errNoMainSolution   = errors.New("no main solution")
+	errSolutionNotFound = errors.New("solution not found")
+)
+
+// solutionsOf returns the reference solutions of problem id with the
+// verdicts of their latest judges.
+func solutionsOf(problemId int64) ([]model.Solution, error) {
+	var solutions []model.Solution
+	if err := engine.Where("problem_id = ?", problemId).Asc("id").Find(&solutions); err != nil {
+		return nil, err
+	}
+	statuses, err := model.SolutionStatuses(engine, solutions)
+	if err != nil {
+		return nil, err
+	}
+	for i := range solutions {
+		s := &solutions[i]
+		s.Lang = strings.ToLower(s.Language.String())
+		s.Expect = s.Expected.String()
+		s.Status = statuses[s.CodeId].String()
+	}
+	return solutions, nil
+}
+
+// mainSolution returns the main solution of problem id, errNoMainSolution
+// if it has none.
+func mainSolution(problemId int64) (model.Solution, error) {
+	var solution model.Solution
+	has, err := engine.Where("problem_id = ? AND main = ?", problemId, true).Get(&solution)
+	if err == nil && !has {
+		err = errNoMainSolution
+	}
+	return solution, err
+}
+
+// judging returns the parts of a revision verdicts depend on, a change of
+// them judges reference solutions again.
+func judging(r model.ProblemRevision) interface{} {
+	var tests [][2]string
+	for _, t := range r.Cases {
+		tests = append(tests, [2]string{t.InputHash, t.OutputHash})
+	}
+	return []interface{}{
+		r.TimeLimit, r.MemoryLimit, r.Type, r.Compare, r.Epsilon,
+		r.CheckerHash, r.InteractorHash, r.InputHash, r.OutputHash, tests,
+	}
+}
+
+// resubmitSolutions submits every reference solution of problem id to
+// judgers in session s, the problem is pending until all of them are
+// judged, which the judger checks against their expected verdicts.
+func resubmitSolutions(s *xorm.Session, problemId int64) error {
+	var solutions []model.Solution
+	if err := s.Where("problem_id = ?", problemId).Find(&solutions); err != nil {
+		return err
+	}
+	var verification string
+	if len(solutions) > 0 {
+		verification = model.Pending
+	}
+	for _, solution := range solutions {
+		code := solution.Code()
+		code.CreatedAt = time.Now()
+		if _, err := s.InsertOne(&code); err != nil {
+			return err
+		}
+		if _, err := s.Id(solution.Id).Cols("code_id").Update(&model.Solution{CodeId: code.Id}); err != nil {
+			return err
+		}
+	}
+	_, err := s.Id(problemId).Cols("verification").Update(&model.Problem{Verification: verification})
+	return err
+}
+
+// resubmitTx makes change and resubmitSolutions in a transaction of its own.
+func resubmitTx(problemId int64, change func(s *xorm.Session) error) error {
+	transaction := engine.NewSession()
+	defer transaction.Close()
+	if err := transaction.Begin(); err != nil {
+		return err
+	}
+	if err := change(transaction); err != nil {
+		transaction.Rollback()
+		return err
+	}
+	if err := resubmitSolutions(transaction, problemId); err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+// listSolutions lists the reference solutions of a problem with their
+// expected and latest verdicts.
+func listSolutions(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	solutions, err := solutionsOf(problem.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"solutions": solutions, "verification": problem.Verification})
+}
+
+// putSolution adds or replaces a reference solution of a problem by name,
+// a main solution replaces the main solution. All reference solutions are
+// judged again.
+func putSolution(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var solution model.Solution
+	if err := c.BindJSON(&solution); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	solution.Name = c.Param("name")
+	if err := solution.Init(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if solution.Source == "" || int64(len(solution.Source)) > juryLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "solution source empty or too large"})
+		return
+	}
+	// stored like sources of codes, which judges of the solution refer to
+	var err error
+	if solution.SourceHash, err = SaveBlob("codes", solution.Source); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	solution.ProblemId = problem.Id
+	err = resubmitTx(problem.Id, func(s *xorm.Session) error {
+		if solution.Main {
+			if _, err := s.Where("problem_id = ?", problem.Id).Cols("main").Update(&model.Solution{}); err != nil {
+				return err
+			}
+		}
+		if _, err := s.Where("problem_id = ? AND name = ?", problem.Id, solution.Name).Delete(&model.Solution{}); err != nil {
+			return err
+		}
+		_, err := s.InsertOne(&solution)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	solution.Source = ""
+	c.JSON(http.StatusOK, gin.H{"solution": solution})
+}
+
+// deleteSolution removes a reference solution of a problem, the others are
+// judged again.
+func deleteSolution(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	// a solution not found rolls back, so nothing is judged again
+	err := resubmitTx(problem.Id, func(s *xorm.Session) error {
+		n, err := s.Where("problem_id = ? AND name = ?", problem.Id, c.Param("name")).Delete(&model.Solution{})
+		if err == nil && n == 0 {
+			err = errSolutionNotFound
+		}
+		return err
+	})
+	if err == errSolutionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// verifyHandler judges the reference solutions of a problem again.
+func verifyHandler(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	err := resubmitTx(problem.Id, func(s *xorm.Session) error { return nil })
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// buildMain compiles the main solution of problem id in dir and returns
+// its binary.
+func buildMain(problemId int64, dir string) (string, error) {
+	solution, err := mainSolution(problemId)
+	if err != nil {
+		return "", err
+	}
+	return buildSolution(solution, dir)
+}
+
+// buildSolution compiles a saved solution in dir and returns its binary.
+func buildSolution(solution model.Solution, dir string) (string, error) {
+	source := filepath.Join(dir, "main."+strings.ToLower(solution.Language.String()))
+	binary := filepath.Join(dir, "main")
+	if err := fetchBlob(solution.SourcePath(), source); err != nil {
+		return "", err
+	}
+	if err := compileSolution(solution.Language, source, binary); err != nil {
+		return "", &solutionError{0, err}
+	}
+	return binary, nil
+}
+
+// solutionError is a solution failing on the nth test case, or failing to
+// compile with Nth 0.
+type solutionError struct {
+	Nth int
+	Err error
+}
+
+func (e *solutionError) Error() string {
+	if e.Nth == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("solution on test %d: %v", e.Nth, e.Err)
+}
+
+// answerCases sets the outputs of cases to the outputs of the solution
+// binary on their inputs, working in dir. Outputs are limited to limit
+// bytes in total.
+func answerCases(binary, dir string, cases []model.TestCase, limit int64) error {
+	var total int64
+	input, output := filepath.Join(dir, "input"), filepath.Join(dir, "output")
+	for i := range cases {
+		t := &cases[i]
+		if err := fetchBlob(t.InputPath(), input); err != nil {
+			return err
+		}
+		if err := runTo(binary, nil, input, output); err != nil {
+			return &solutionError{i + 1, err}
+		}
+		f, err := os.Open(output)
+		if err != nil {
+			return err
+		}
+		digest, size, err := SaveBlobFrom("problems", f, limit-total)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		total += size
+		t.Set("output", digest, size)
+	}
+	return nil
+}
+
+// answerStatus returns the status code of an error of answerCases.
+func answerStatus(err error) int {
+	switch err.(type) {
+	case *solutionError:
+		return http.StatusBadRequest
+	}
+	switch err {
+	case errNoMainSolution:
+		return http.StatusBadRequest
+	case ErrQuotaExceeded:
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+// answerTests sets the outputs of cases to the outputs of the main solution
+// of problem with limit bytes in total, or responds with the error.
+func answerTests(c *gin.Context, problem model.Problem, cases []model.TestCase, limit int64) bool {
+	dir, err := confine.Contestant.TempDir("answer-")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	defer os.RemoveAll(dir)
+	binary, err := buildMain(problem.Id, dir)
+	if err == nil {
+		err = answerCases(binary, dir, cases, limit)
+	}
+	if err != nil {
+		c.JSON(answerStatus(err), gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// answer replaces the outputs of the test cases of a problem with the
+// outputs of its main solution.
+func answer(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	cases, err := casesOf(problem.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(cases) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no test cases"})
+		return
+	}
+	var inputs int64
+	for _, t := range cases {
+		inputs += t.InputSize
+	}
+	if !answerTests(c, problem, cases, dataQuota-inputs) {
+		return
+	}
+	if err := replaceCasesTx(problem, cases); err != nil {
+		c.JSON(replaceStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cases": cases})
+}
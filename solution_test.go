@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+func TestJudging(t *testing.T) {
+	a := model.ProblemRevision{
+		TimeLimit: 1000,
+		Cases:     []model.TestCase{{Id: 1, Nth: 1, InputHash: "in", OutputHash: "out"}},
+	}
+	b := a
+	b.Title, b.Cases = "renamed", []model.TestCase{{Id: 7, Nth: 1, InputHash: "in", OutputHash: "out"}}
+	if !reflect.DeepEqual(judging(a), judging(b)) {
+		t.Error("title and ids of test cases change verdicts")
+	}
+	for _, change := range []func(r *model.ProblemRevision){
+		func(r *model.ProblemRevision) { r.TimeLimit = 2000 },
+		func(r *model.ProblemRevision) { r.CheckerHash = "checker" },
+		func(r *model.ProblemRevision) {
+			r.Cases = []model.TestCase{{Nth: 1, InputHash: "in", OutputHash: "other"}}
+		},
+		func(r *model.ProblemRevision) { r.Cases = nil },
+	} {
+		b := a
+		change(&b)
+		if reflect.DeepEqual(judging(a), judging(b)) {
+			t.Errorf("%+v does not change verdicts", b)
+		}
+	}
+}
+
+func TestSolutionInit(t *testing.T) {
+	s := model.Solution{Name: "main", Lang: "cpp", Main: true}
+	if err := s.Init(); err != nil || s.Expected != model.Accept || s.Language != model.CPP {
+		t.Errorf("init main solution: %+v, %v", s, err)
+	}
+	s = model.Solution{Name: "slow", Lang: "go", Expect: "TimeLimitExceeded"}
+	if err := s.Init(); err != nil || s.Expected != model.TimeLimitExceeded {
+		t.Errorf("init slow solution: %+v, %v", s, err)
+	}
+	for _, s := range []model.Solution{
+		{Lang: "cpp", Expect: "Accept"},
+		{Name: "wa", Lang: "cpp", Expect: "WA"},
+		{Name: "wa", Lang: "cpp", Expect: "Handling"},
+		{Name: "main", Lang: "cpp", Expect: "WrongAnswer", Main: true},
+		{Name: "java", Lang: "java", Expect: "Accept"},
+	} {
+		if err := s.Init(); err == nil {
+			t.Errorf("init %+v without error", s)
+		}
+	}
+}
+
+func TestAnswerCases(t *testing.T) {
+	store = storage.NewMemory()
+	dir, err := confine.Contestant.TempDir("answer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the solution prints the sum of two numbers
+	binary := filepath.Join(dir, "sum")
+	script := "#!/bin/sh\nread a b\nif [ -z \"$b\" ]; then exit 1; fi\necho $((a + b))\n"
+	if err := ioutil.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var cases []model.TestCase
+	for _, input := range []string{"1 2\n", "40 2\n"} {
+		digest, err := SaveBlob("problems", input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, model.TestCase{Nth: len(cases) + 1, InputHash: digest})
+	}
+	if err := answerCases(binary, dir, cases, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"3\n", "42\n"} {
+		if cases[i].OutputHash != storage.Digest([]byte(want)) || cases[i].OutputSize != int64(len(want)) {
+			t.Errorf("output of test %d is not %q", i+1, want)
+		}
+	}
+	if err := answerCases(binary, dir, cases, 2); err != ErrQuotaExceeded {
+		t.Errorf("answer over quota: %v", err)
+	}
+
+	digest, err := SaveBlob("problems", "1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases = append(cases, model.TestCase{Nth: 3, InputHash: digest})
+	err = answerCases(binary, dir, cases, 1<<20)
+	if e, ok := err.(*solutionError); !ok || e.Nth != 3 || answerStatus(err) != 400 {
+		t.Errorf("answer a failing test: %v", err)
+	}
+}
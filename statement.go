@@ -0,0 +1,297 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// fallbackLocale is the locale of statements shown when none of the locales
+// asked for is found, set by STATEMENT_FALLBACK_LOCALE.
+var fallbackLocale = "en"
+
+var errStatementNotFound = errors.New("statement not found")
+
+func init() {
+	if locale := os.Getenv("STATEMENT_FALLBACK_LOCALE"); locale != "" {
+		fallbackLocale = locale
+	}
+}
+
+// statementsOf returns the statements of a problem by locale.
+func statementsOf(problemId int64) ([]model.Statement, error) {
+	var statements []model.Statement
+	err := engine.Where("problem_id = ?", problemId).Asc("locale").Find(&statements)
+	return statements, err
+}
+
+// checkStatements canonicalizes the locales of statements, which must be
+// different.
+func checkStatements(statements []model.Statement) error {
+	seen := make(map[string]bool)
+	for i := range statements {
+		locale, err := model.CanonicalLocale(statements[i].Locale)
+		if err != nil {
+			return err
+		}
+		if seen[locale] {
+			return fmt.Errorf("duplicate statement of locale %s", locale)
+		}
+		seen[locale] = true
+		statements[i].Locale = locale
+	}
+	return nil
+}
+
+// replaceStatements replaces the statements of problem id with statements
+// in session s.
+func replaceStatements(s *xorm.Session, problemId int64, statements []model.Statement) error {
+	if _, err := s.Where("problem_id = ?", problemId).Delete(&model.Statement{}); err != nil {
+		return err
+	}
+	for i := range statements {
+		statements[i].Id = 0
+		statements[i].ProblemId = problemId
+		if _, err := s.InsertOne(&statements[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preferredLocales returns the locales asked for by the "lang" query and the
+// Accept-Language header, the most preferred first.
+func preferredLocales(c *gin.Context) []string {
+	var locales []string
+	if lang := c.Query("lang"); lang != "" {
+		locales = append(locales, lang)
+	}
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	var accepted []weighted
+	for _, part := range strings.Split(c.Request.Header.Get("Accept-Language"), ",") {
+		params := strings.Split(part, ";")
+		locale := strings.TrimSpace(params[0])
+		if locale == "" || locale == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			accepted = append(accepted, weighted{locale, q})
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+	for _, w := range accepted {
+		locales = append(locales, w.locale)
+	}
+	return locales
+}
+
+// chooseStatement returns the statement of the most preferred locale, a
+// locale of the same language is taken if the locale is not found. It falls
+// back to fallbackLocale, then to any statement.
+func chooseStatement(statements []model.Statement, preferred []string) (model.Statement, bool) {
+	if len(statements) == 0 {
+		return model.Statement{}, false
+	}
+	language := func(locale string) string {
+		return strings.ToLower(strings.SplitN(locale, "-", 2)[0])
+	}
+	candidates := append(append([]string(nil), preferred...), fallbackLocale)
+	for _, locale := range candidates {
+		for _, statement := range statements {
+			if strings.EqualFold(statement.Locale, locale) {
+				return statement, true
+			}
+		}
+		for _, statement := range statements {
+			if language(statement.Locale) == language(locale) {
+				return statement, true
+			}
+		}
+	}
+	return statements[0], true
+}
+
+// statementPolicy allows the HTML of user content without scripts or styles.
+var statementPolicy = bluemonday.UGCPolicy()
+
+// renderMarkdown renders Markdown with LaTeX math of a statement of problem
+// id to sanitized HTML. Math between $ or $$ is kept as \( \) or \[ \] in
+// spans of class "math inline" or "math display" for MathJax or KaTeX in the
+// browser. Links to "attachment:name" point to attachments of the problem.
+func renderMarkdown(problemId int64, source string) string {
+	text, math := extractMath(source)
+	// unsafe links are removed by the sanitizer
+	renderer := blackfriday.HtmlRenderer(blackfriday.HTML_NOFOLLOW_LINKS, "", "")
+	extensions := blackfriday.EXTENSION_NO_INTRA_EMPHASIS | blackfriday.EXTENSION_TABLES |
+		blackfriday.EXTENSION_FENCED_CODE | blackfriday.EXTENSION_AUTOLINK |
+		blackfriday.EXTENSION_STRIKETHROUGH | blackfriday.EXTENSION_SPACE_HEADERS
+	out := string(blackfriday.Markdown([]byte(text), renderer, extensions))
+	out = statementPolicy.Sanitize(linkAttachments(out, problemId))
+	// math is escaped by itself, so it is put back after sanitizing
+	for i, m := range math {
+		out = strings.Replace(out, mathPlaceholder(i), m, 1)
+	}
+	return out
+}
+
+// mathPlaceholder is the text math i is replaced with while rendering, it
+// has nothing Markdown or HTML would change.
+func mathPlaceholder(i int) string {
+	return "MATHPLACEHOLDER" + strconv.Itoa(i) + "END"
+}
+
+// extractMath replaces math in source with placeholders and returns the
+// HTML of math by placeholder. Math in code is left and escaped dollars are
+// unescaped.
+func extractMath(source string) (string, []string) {
+	var b strings.Builder
+	var math []string
+	for i := 0; i < len(source); {
+		switch {
+		case source[i] == '\\' && i+1 < len(source):
+			// Markdown does not escape dollars
+			if source[i+1] == '$' {
+				b.WriteByte('$')
+			} else {
+				b.WriteString(source[i : i+2])
+			}
+			i += 2
+			continue
+		case source[i] == '`':
+			// a code span or a fence ends with the same number of backticks
+			n := len(source[i:]) - len(strings.TrimLeft(source[i:], "`"))
+			fence := source[i : i+n]
+			end := strings.Index(source[i+n:], fence)
+			if end < 0 {
+				b.WriteString(fence)
+				i += n
+				continue
+			}
+			end += i + 2*n
+			b.WriteString(source[i:end])
+			i = end
+			continue
+		case source[i] == '$':
+			delim, open, close := "$", `<span class="math inline">\(`, `\)</span>`
+			if strings.HasPrefix(source[i:], "$$") {
+				delim, open, close = "$$", `<span class="math display">\[`, `\]</span>`
+			}
+			start := i + len(delim)
+			if end := strings.Index(source[start:], delim); end > 0 {
+				b.WriteString(mathPlaceholder(len(math)))
+				math = append(math, open+html.EscapeString(source[start:start+end])+close)
+				i = start + end + len(delim)
+				continue
+			}
+		}
+		b.WriteByte(source[i])
+		i++
+	}
+	return b.String(), math
+}
+
+// renderStatement returns statement with its sections rendered to HTML.
+func renderStatement(statement model.Statement) model.Statement {
+	statement.Legend = renderMarkdown(statement.ProblemId, statement.Legend)
+	statement.Input = renderMarkdown(statement.ProblemId, statement.Input)
+	statement.Output = renderMarkdown(statement.ProblemId, statement.Output)
+	statement.Notes = renderMarkdown(statement.ProblemId, statement.Notes)
+	return statement
+}
+
+// listStatements lists the statements of a problem in Markdown.
+func listStatements(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	statements, err := statementsOf(problem.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"statements": statements})
+}
+
+// putStatement adds or replaces the statement of a problem in a locale.
+func putStatement(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var statement model.Statement
+	if err := c.BindJSON(&statement); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	locale, err := model.CanonicalLocale(c.Param("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	statement.Id, statement.ProblemId, statement.Locale = 0, problem.Id, locale
+	err = revise(problem.Id, func(s *xorm.Session) error {
+		if _, err := s.Where("problem_id = ? AND locale = ?", problem.Id, locale).Delete(&model.Statement{}); err != nil {
+			return err
+		}
+		_, err := s.InsertOne(&statement)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"statement": statement})
+}
+
+// deleteStatement removes the statement of a problem in a locale.
+func deleteStatement(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	locale, err := model.CanonicalLocale(c.Param("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = revise(problem.Id, func(s *xorm.Session) error {
+		n, err := s.Where("problem_id = ? AND locale = ?", problem.Id, locale).Delete(&model.Statement{})
+		if err == nil && n == 0 {
+			err = errStatementNotFound
+		}
+		return err
+	})
+	if err == errStatementNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		source, want string
+	}{
+		{"**a** and $a_1 < b_2$", `<p><strong>a</strong> and <span class="math inline">\(a_1 &lt; b_2\)</span></p>` + "\n"},
+		{"$$\\sum_{i=1}^n *i*$$", `<p><span class="math display">\[\sum_{i=1}^n *i*\]</span></p>` + "\n"},
+		{"costs \\$5 and `$x$`", "<p>costs $5 and <code>$x$</code></p>\n"},
+		{"<script>alert(1)</script>[x](javascript:alert(1))", "<p>x</p>\n"},
+		{"![graph](attachment:graph.png) [data](attachment:data.zip)", `<p><img src="/problem/1/attachments/graph.png" alt="graph"> <a href="/problem/1/attachments/data.zip" rel="nofollow">data</a></p>` + "\n"},
+	}
+	for _, test := range tests {
+		if got := renderMarkdown(1, test.source); got != test.want {
+			t.Errorf("render %q to %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestChooseStatement(t *testing.T) {
+	req, err := http.NewRequest("GET", "/problem/1?lang=fr", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Language", "en;q=0.5, zh-TW, de;q=0")
+	preferred := preferredLocales(&gin.Context{Request: req})
+	if want := []string{"fr", "zh-TW", "en"}; !reflect.DeepEqual(preferred, want) {
+		t.Errorf("get preferred locales %v, want %v", preferred, want)
+	}
+	statements := []model.Statement{{Locale: "de"}, {Locale: "en"}, {Locale: "zh-CN"}}
+	for _, test := range []struct {
+		preferred []string
+		want      string
+	}{
+		{preferred, "zh-CN"},
+		{[]string{"EN"}, "en"},
+		{[]string{"ja"}, fallbackLocale},
+		{nil, fallbackLocale},
+	} {
+		if statement, _ := chooseStatement(statements, test.preferred); statement.Locale != test.want {
+			t.Errorf("choose %s for %v, want %s", statement.Locale, test.preferred, test.want)
+		}
+	}
+	if statement, _ := chooseStatement(statements[:1], []string{"ja"}); statement.Locale != "de" {
+		t.Errorf("choose %s without the fallback locale, want de", statement.Locale)
+	}
+	if _, ok := chooseStatement(nil, preferred); ok {
+		t.Error("choose a statement of none")
+	}
+}
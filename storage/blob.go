@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// ErrCorrupt is returned when the content of a file does not match its digest.
+var ErrCorrupt = errors.New("storage: content does not match digest")
+
+// Digest returns the hex encoded SHA-256 digest of content.
+func Digest(content []byte) string {
+	h := sha256.Sum256(content)
+	return hex.EncodeToString(h[:])
+}
+
+// BlobRefresh is how long a blob is deduplicated after it is written, an
+// older blob is written again to refresh its modification time, so a
+// garbage collector keeping objects modified within a grace period longer
+// than BlobRefresh keeps blobs in use.
+var BlobRefresh = time.Hour
+
+// PutIfAbsent puts r with the hex encoded SHA-256 digest to key unless the
+// object there has the digest and was written within BlobRefresh, it is
+// used to deduplicate content addressed files.
+func PutIfAbsent(s Storage, key string, r io.Reader, size int64, digest string) error {
+	info, err := s.Stat(key)
+	if err != nil && err != ErrNotExist {
+		return err
+	}
+	if err == nil && info.Size == size && time.Since(info.ModTime) < BlobRefresh {
+		ok, err := hasDigest(s, key, digest)
+		if err != nil || ok {
+			return err
+		}
+	}
+	return s.Put(key, r, size)
+}
+
+// hasDigest reports whether the content of key has the digest.
+func hasDigest(s Storage, key, digest string) (bool, error) {
+	rc, err := s.Get(key)
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+	_, err = io.Copy(ioutil.Discard, NewVerifier(rc, digest))
+	if err == ErrCorrupt {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+type verifier struct {
+	r      io.Reader
+	h      hash.Hash
+	digest string
+}
+
+// NewVerifier returns a reader reading r, it returns ErrCorrupt instead of
+// io.EOF if the content read does not match the hex encoded SHA-256 digest.
+func NewVerifier(r io.Reader, digest string) io.Reader {
+	return &verifier{r: r, h: sha256.New(), digest: digest}
+}
+
+func (v *verifier) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(v.h.Sum(nil)) != v.digest {
+		return n, ErrCorrupt
+	}
+	return n, err
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+const (
+	// metadata of compressed objects, metaSize is the uncompressed size
+	metaEncoding = "encoding"
+	metaSize     = "size"
+
+	// objects smaller than minCompressSize are not worth compressing
+	minCompressSize = 1024
+)
+
+// Gzip decorates a storage to transparently gzip objects on Put and
+// decompress them on Get. The encoding and the uncompressed size are kept in
+// the metadata of each object, so objects written without compression are
+// read as they are.
+type Gzip struct {
+	MetaStorage
+	compress bool
+}
+
+// NewGzip decorates s, objects are only compressed when compress is set,
+// compressed objects are always readable.
+func NewGzip(s MetaStorage, compress bool) *Gzip {
+	return &Gzip{MetaStorage: s, compress: compress}
+}
+
+func (g *Gzip) Put(key string, r io.Reader, size int64) error {
+	if !g.compress || size < minCompressSize {
+		return g.MetaStorage.Put(key, r, size)
+	}
+	// the compressed size must be known before putting, so spool it
+	f, err := ioutil.TempFile("", "gzip-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	w := gzip.NewWriter(f)
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	n, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	meta := Meta{metaEncoding: "gzip", metaSize: strconv.FormatInt(size, 10)}
+	return g.MetaStorage.PutMeta(key, f, n, meta)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (g *Gzip) Get(key string) (io.ReadCloser, error) {
+	rc, info, err := g.MetaStorage.GetMeta(key)
+	if err != nil {
+		return nil, err
+	}
+	if info.Meta[metaEncoding] != "gzip" {
+		return rc, nil
+	}
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return readCloser{zr, rc}, nil
+}
+
+// Stat returns the uncompressed size of key. List is not decorated, it
+// returns the sizes actually stored.
+func (g *Gzip) Stat(key string) (Info, error) {
+	info, err := g.MetaStorage.Stat(key)
+	if err != nil || info.Meta[metaEncoding] != "gzip" {
+		return info, err
+	}
+	size, err := strconv.ParseInt(info.Meta[metaSize], 10, 64)
+	if err != nil {
+		return Info{}, err
+	}
+	info.Size = size
+	return info, nil
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	m := NewMemory()
+	testStorage(t, NewGzip(m, true))
+
+	g := NewGzip(m, true)
+	content := strings.Repeat("1 2\n", 1000)
+	if err := g.Put("problems/big.txt", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := m.Stat("problems/big.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Size >= int64(len(content)) {
+		t.Fatalf("stored %d bytes of %d, not compressed", raw.Size, len(content))
+	}
+	info, err := g.Stat("problems/big.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(content)) {
+		t.Fatalf("stat size %d, want %d", info.Size, len(content))
+	}
+	r, err := g.Get("problems/big.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != content {
+		t.Fatal("content changed after compression")
+	}
+
+	// objects written before compression is enabled are still readable
+	old := strings.Repeat("3\n", 1000)
+	if err := m.Put("problems/old.txt", strings.NewReader(old), int64(len(old))); err != nil {
+		t.Fatal(err)
+	}
+	r, err = NewGzip(m, false).Get("problems/old.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != old {
+		t.Fatal("uncompressed object changed")
+	}
+
+	// only the metadata tells whether an object is compressed
+	var zipped bytes.Buffer
+	w := gzip.NewWriter(&zipped)
+	w.Write([]byte(old))
+	w.Close()
+	if err := m.Put("attachments/old.gz", bytes.NewReader(zipped.Bytes()), int64(zipped.Len())); err != nil {
+		t.Fatal(err)
+	}
+	r, err = g.Get("attachments/old.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, zipped.Bytes()) {
+		t.Fatal("uncompressed gzip file is decompressed")
+	}
+}
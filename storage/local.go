@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// metaPrefix starts the names of the files keeping metadata of objects, the
+// metadata of dir/name is kept in dir/.meta-name.
+const metaPrefix = ".meta-"
+
+// Local stores files in a directory of the local filesystem.
+type Local struct {
+	root string
+}
+
+// NewLocal creates a local storage rooted at dir, creating dir if needed.
+func NewLocal(dir string) (*Local, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{root: dir}, nil
+}
+
+func (l *Local) path(key string) string {
+	return filepath.Join(l.root, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+// metaPath returns the path of the file keeping the metadata of key.
+func (l *Local) metaPath(key string) string {
+	p := l.path(key)
+	return filepath.Join(filepath.Dir(p), metaPrefix+filepath.Base(p))
+}
+
+func (l *Local) Put(key string, r io.Reader, size int64) error {
+	return l.PutMeta(key, r, size, nil)
+}
+
+func (l *Local) PutMeta(key string, r io.Reader, size int64, meta Meta) error {
+	p := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first so readers never see half written files
+	f, err := ioutil.TempFile(filepath.Dir(p), ".put-")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := l.writeMeta(key, meta); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// writeMeta replaces the metadata of key, the file is removed if meta is
+// empty.
+func (l *Local) writeMeta(key string, meta Meta) error {
+	p := l.metaPath(key)
+	if len(meta) == 0 {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(p), ".put-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// readMeta returns the metadata of key, nil if it has none.
+func (l *Local) readMeta(key string) (Meta, error) {
+	data, err := ioutil.ReadFile(l.metaPath(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var meta Meta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+func (l *Local) Get(key string) (io.ReadCloser, error) {
+	f, err := os.Open(l.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	return f, err
+}
+
+func (l *Local) GetMeta(key string) (io.ReadCloser, Info, error) {
+	f, err := os.Open(l.path(key))
+	if os.IsNotExist(err) {
+		return nil, Info{}, ErrNotExist
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+	info, err := l.info(key, f.Stat)
+	if err != nil {
+		f.Close()
+		return nil, Info{}, err
+	}
+	return f, info, nil
+}
+
+func (l *Local) Delete(key string) error {
+	for _, p := range []string{l.path(key), l.metaPath(key)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Local) Stat(key string) (Info, error) {
+	return l.info(key, func() (os.FileInfo, error) {
+		return os.Stat(l.path(key))
+	})
+}
+
+// info returns the info of key with its metadata, stat returns the file
+// info of the object.
+func (l *Local) info(key string, stat func() (os.FileInfo, error)) (Info, error) {
+	fi, err := stat()
+	if os.IsNotExist(err) {
+		return Info{}, ErrNotExist
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	meta, err := l.readMeta(key)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Key: key, Size: fi.Size(), ModTime: fi.ModTime(), Meta: meta}, nil
+}
+
+func (l *Local) List(prefix string) ([]Info, error) {
+	var infos []Info
+	err := filepath.Walk(l.root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".put-") || strings.HasPrefix(fi.Name(), metaPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, Info{Key: key, Size: fi.Size(), ModTime: fi.ModTime()})
+		}
+		return nil
+	})
+	return infos, err
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type object struct {
+	data    []byte
+	modTime time.Time
+	meta    Meta
+}
+
+// Memory keeps files in memory, it is meant for tests.
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]object
+}
+
+// NewMemory creates an empty memory storage.
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string]object)}
+}
+
+func (m *Memory) Put(key string, r io.Reader, size int64) error {
+	return m.PutMeta(key, r, size, nil)
+}
+
+func (m *Memory) PutMeta(key string, r io.Reader, size int64, meta Meta) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.objects[key] = object{data: data, modTime: time.Now(), meta: meta}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Get(key string) (io.ReadCloser, error) {
+	r, _, err := m.GetMeta(key)
+	return r, err
+}
+
+func (m *Memory) GetMeta(key string) (io.ReadCloser, Info, error) {
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, Info{}, ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(obj.data)), obj.info(key), nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	delete(m.objects, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Stat(key string) (Info, error) {
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return Info{}, ErrNotExist
+	}
+	return obj.info(key), nil
+}
+
+func (m *Memory) List(prefix string) ([]Info, error) {
+	var infos []Info
+	m.mu.RLock()
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, Info{Key: key, Size: int64(len(obj.data)), ModTime: obj.modTime})
+		}
+	}
+	m.mu.RUnlock()
+	sort.Sort(byKey(infos))
+	return infos, nil
+}
+
+func (obj object) info(key string) Info {
+	return Info{Key: key, Size: int64(len(obj.data)), ModTime: obj.modTime, Meta: obj.meta}
+}
+
+type byKey []Info
+
+func (s byKey) Len() int           { return len(s) }
+func (s byKey) Less(i, j int) bool { return s[i].Key < s[j].Key }
+func (s byKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Mirror writes to both a primary and a secondary storage and reads from
+// the primary, falling back to the secondary for missing keys. It keeps a
+// new storage up to date while old files are migrated to it.
+type Mirror struct {
+	Primary   Storage
+	Secondary Storage
+}
+
+// NewMirror creates a mirror of primary and secondary.
+func NewMirror(primary, secondary Storage) *Mirror {
+	return &Mirror{Primary: primary, Secondary: secondary}
+}
+
+func (m *Mirror) Put(key string, r io.Reader, size int64) error {
+	// r can be read only once, so spool it for the second write
+	f, err := ioutil.TempFile("", "mirror-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := m.Primary.Put(key, io.TeeReader(r, f), size); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return m.Secondary.Put(key, f, size)
+}
+
+func (m *Mirror) Get(key string) (io.ReadCloser, error) {
+	r, err := m.Primary.Get(key)
+	if err == ErrNotExist {
+		return m.Secondary.Get(key)
+	}
+	return r, err
+}
+
+func (m *Mirror) Delete(key string) error {
+	if err := m.Primary.Delete(key); err != nil {
+		return err
+	}
+	return m.Secondary.Delete(key)
+}
+
+func (m *Mirror) Stat(key string) (Info, error) {
+	info, err := m.Primary.Stat(key)
+	if err == ErrNotExist {
+		return m.Secondary.Stat(key)
+	}
+	return info, err
+}
+
+func (m *Mirror) List(prefix string) ([]Info, error) {
+	return m.Primary.List(prefix)
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"qiniupkg.com/api.v7/kodo"
+	"qiniupkg.com/x/rpc.v7"
+)
+
+// qiniuMimeType is the MIME type of objects put with metadata, the metadata
+// is kept in its parameters since kodo keeps nothing else with objects.
+const qiniuMimeType = "application/octet-stream"
+
+// Qiniu stores files in a Qiniu kodo bucket, files are downloaded from the
+// bucket domain.
+type Qiniu struct {
+	bucket kodo.Bucket
+	domain string
+}
+
+// NewQiniu creates a Qiniu storage of bucket, downloading from domain.
+func NewQiniu(accessKey, secretKey, bucket, domain string) *Qiniu {
+	c := kodo.New(0, &kodo.Config{AccessKey: accessKey, SecretKey: secretKey})
+	return &Qiniu{bucket: c.Bucket(bucket), domain: domain}
+}
+
+// qiniuNotExist reports whether err is qiniu's "no such file or directory".
+func qiniuNotExist(err error) bool {
+	info, ok := err.(*rpc.ErrorInfo)
+	return ok && info.Code == 612
+}
+
+// qiniuMeta returns the metadata kept in mimeType.
+func qiniuMeta(mimeType string) Meta {
+	mediaType, params, err := mime.ParseMediaType(mimeType)
+	if err != nil || mediaType != qiniuMimeType || len(params) == 0 {
+		return nil
+	}
+	return Meta(params)
+}
+
+func (q *Qiniu) Put(key string, r io.Reader, size int64) error {
+	return q.PutMeta(key, r, size, nil)
+}
+
+func (q *Qiniu) PutMeta(key string, r io.Reader, size int64, meta Meta) error {
+	var extra *kodo.PutExtra
+	if len(meta) > 0 {
+		extra = &kodo.PutExtra{MimeType: mime.FormatMediaType(qiniuMimeType, meta)}
+	}
+	return q.bucket.Put(nil, nil, key, r, size, extra)
+}
+
+func (q *Qiniu) Get(key string) (io.ReadCloser, error) {
+	r, _, err := q.GetMeta(key)
+	return r, err
+}
+
+func (q *Qiniu) GetMeta(key string) (io.ReadCloser, Info, error) {
+	baseUrl := kodo.MakeBaseUrl(q.domain, key) // download url
+	resp, err := http.Get(baseUrl)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, Info{}, ErrNotExist
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, Info{}, fmt.Errorf("Status code %d", resp.StatusCode)
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	info := Info{
+		Key:     key,
+		Size:    resp.ContentLength,
+		ModTime: modTime,
+		Meta:    qiniuMeta(resp.Header.Get("Content-Type")),
+	}
+	return resp.Body, info, nil
+}
+
+func (q *Qiniu) Delete(key string) error {
+	if err := q.bucket.Delete(nil, key); err != nil && !qiniuNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (q *Qiniu) Stat(key string) (Info, error) {
+	entry, err := q.bucket.Stat(nil, key)
+	if qiniuNotExist(err) {
+		return Info{}, ErrNotExist
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	// PutTime is in units of 100ns
+	return Info{
+		Key:     key,
+		Size:    entry.Fsize,
+		ModTime: time.Unix(0, entry.PutTime*100),
+		Meta:    qiniuMeta(entry.MimeType),
+	}, nil
+}
+
+func (q *Qiniu) List(prefix string) ([]Info, error) {
+	var (
+		infos  []Info
+		marker string
+	)
+	for {
+		items, _, next, err := q.bucket.List(nil, prefix, "", marker, 1000)
+		for _, item := range items {
+			infos = append(infos, Info{Key: item.Key, Size: item.Fsize, ModTime: time.Unix(0, item.PutTime*100)})
+		}
+		if err == io.EOF {
+			return infos, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		marker = next
+	}
+}
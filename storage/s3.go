@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	emptySHA256     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// metadata is kept in user defined headers starting with s3MetaPrefix
+	s3MetaPrefix = "x-amz-meta-"
+)
+
+// S3 stores files in a bucket of an S3 compatible object storage such as
+// MinIO, requests are signed with AWS signature version 4 and use path style
+// addressing.
+type S3 struct {
+	Endpoint  string // like "http://127.0.0.1:9000"
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+
+	now func() time.Time
+}
+
+// NewS3 creates an S3 storage of bucket at endpoint.
+func NewS3(endpoint, bucket, region, accessKey, secretKey string) *S3 {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Bucket:    bucket,
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client:    http.DefaultClient,
+		now:       time.Now,
+	}
+}
+
+func (s *S3) Put(key string, r io.Reader, size int64) error {
+	return s.PutMeta(key, r, size, nil)
+}
+
+func (s *S3) PutMeta(key string, r io.Reader, size int64, meta Meta) error {
+	req, err := s.newRequest("PUT", key, nil, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	for k, v := range meta {
+		req.Header.Set(s3MetaPrefix+k, v)
+	}
+	resp, err := s.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	r, _, err := s.GetMeta(key)
+	return r, err
+}
+
+func (s *S3) GetMeta(key string) (io.ReadCloser, Info, error) {
+	req, err := s.newRequest("GET", key, nil, nil)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	resp, err := s.do(req, emptySHA256)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	return resp.Body, s3Info(key, resp), nil
+}
+
+func (s *S3) Delete(key string) error {
+	req, err := s.newRequest("DELETE", key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, emptySHA256)
+	if err == ErrNotExist {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Stat(key string) (Info, error) {
+	req, err := s.newRequest("HEAD", key, nil, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	resp, err := s.do(req, emptySHA256)
+	if err != nil {
+		return Info{}, err
+	}
+	resp.Body.Close()
+	return s3Info(key, resp), nil
+}
+
+// s3Info returns the info of key from the response of a GET or HEAD.
+func s3Info(key string, resp *http.Response) Info {
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	info := Info{Key: key, Size: resp.ContentLength, ModTime: modTime}
+	for k, v := range resp.Header {
+		if k = strings.ToLower(k); strings.HasPrefix(k, s3MetaPrefix) && len(v) > 0 {
+			if info.Meta == nil {
+				info.Meta = make(Meta)
+			}
+			info.Meta[strings.TrimPrefix(k, s3MetaPrefix)] = v[0]
+		}
+	}
+	return info
+}
+
+// listBucketResult is the response of ListObjectsV2.
+type listBucketResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+}
+
+func (s *S3) List(prefix string) ([]Info, error) {
+	var (
+		infos []Info
+		token string
+	)
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.newRequest("GET", "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, emptySHA256)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			infos = append(infos, Info{Key: c.Key, Size: c.Size, ModTime: c.LastModified})
+		}
+		if !result.IsTruncated {
+			return infos, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// newRequest creates a request of key in the bucket, an empty key means the
+// bucket itself.
+func (s *S3) newRequest(method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	path := "/" + s.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+	return http.NewRequest(method, u.String(), body)
+}
+
+// do signs and sends req, responses with non 2xx status are turned into
+// errors.
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, s.now())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotExist
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("s3: %s %s: status code %d: %s", req.Method, req.URL.Path, resp.StatusCode, msg)
+}
+
+// sign adds an AWS signature version 4 Authorization header to req.
+func (s *S3) sign(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if k == "content-type" || k == "range" || strings.HasPrefix(k, "x-amz-") {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders string
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		",SignedHeaders="+signedHeaders+",Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// uriEncode encodes s as required by signature version 4, slashes are kept
+// unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			buf = append(buf, c)
+		case c == '/' && !encodeSlash:
+			buf = append(buf, c)
+		default:
+			buf = append(buf, '%', "0123456789ABCDEF"[c>>4], "0123456789ABCDEF"[c&15])
+		}
+	}
+	return string(buf)
+}
+
+// canonicalQuery encodes query sorted by key.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
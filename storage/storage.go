@@ -0,0 +1,117 @@
+// Package storage abstracts where problem tests and code sources are kept.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrNotExist is returned when a key is not in the storage.
+var ErrNotExist = errors.New("storage: key does not exist")
+
+// Info describes a stored object.
+type Info struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+	// Meta is the metadata put with the object, it is only returned by Stat
+	// and GetMeta.
+	Meta Meta
+}
+
+// Meta is metadata kept with an object, like how the object is encoded.
+// Keys are lower case words.
+type Meta map[string]string
+
+// Storage is a flat key-value store of files, keys are slash separated
+// paths like "problems/1-input.txt".
+type Storage interface {
+	// Put writes size bytes read from r to key, replacing any old content.
+	Put(key string, r io.Reader, size int64) error
+	// Get opens key for reading, it is the caller's reponsibility to close it.
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes key, deleting a missing key is not an error.
+	Delete(key string) error
+	// Stat returns the info of key.
+	Stat(key string) (Info, error)
+	// List returns all objects whose key starts with prefix.
+	List(prefix string) ([]Info, error)
+}
+
+// MetaStorage is a storage which keeps metadata with objects.
+type MetaStorage interface {
+	Storage
+	// PutMeta is Put also keeping meta with key, Put keeps no metadata.
+	PutMeta(key string, r io.Reader, size int64, meta Meta) error
+	// GetMeta is Get also returning the info of key with its metadata.
+	GetMeta(key string) (io.ReadCloser, Info, error)
+}
+
+// FromEnv creates the storage selected by the STORAGE environment variable:
+// "qiniu" (default), "s3", "local" rooted at STORAGE_DIR, or "memory".
+// Objects are gzipped if STORAGE_COMPRESS is "gzip". If STORAGE_DUAL_WRITE
+// is set, writes also go to the storage configured by the same variables
+// prefixed with "DEST_", which is used to migrate to a new storage.
+func FromEnv() (Storage, error) {
+	s, err := FromEnvPrefix("")
+	if err != nil {
+		return nil, err
+	}
+	if os.Getenv("STORAGE_DUAL_WRITE") == "" {
+		return s, nil
+	}
+	dest, err := FromEnvPrefix("DEST_")
+	if err != nil {
+		return nil, err
+	}
+	return NewMirror(s, dest), nil
+}
+
+// FromEnvPrefix is FromEnv reading environment variables prefixed with
+// prefix, without dual writes.
+func FromEnvPrefix(prefix string) (Storage, error) {
+	s, err := backendFromEnv(prefix)
+	if err != nil {
+		return nil, err
+	}
+	switch compress := os.Getenv(prefix + "STORAGE_COMPRESS"); compress {
+	case "", "none":
+		return NewGzip(s, false), nil
+	case "gzip":
+		return NewGzip(s, true), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %s", compress)
+	}
+}
+
+func backendFromEnv(prefix string) (MetaStorage, error) {
+	env := func(key string) string {
+		return os.Getenv(prefix + key)
+	}
+	switch kind := env("STORAGE"); kind {
+	case "", "qiniu":
+		return NewQiniu(
+			env("QINIU_ACCESS_KEY"),
+			env("QINIU_SECRET_KEY"),
+			env("QINIU_BUCKET"),
+			env("QINIU_DOMAIN"),
+		), nil
+	case "s3":
+		return NewS3(
+			env("S3_ENDPOINT"),
+			env("S3_BUCKET"),
+			env("S3_REGION"),
+			env("S3_ACCESS_KEY"),
+			env("S3_SECRET_KEY"),
+		), nil
+	case "local":
+		return NewLocal(env("STORAGE_DIR"))
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage %s", kind)
+	}
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testStorage(t *testing.T, s Storage) {
+	if err := s.Put("problems/1-input.txt", strings.NewReader("1 2\n"), 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("codes/1.c", strings.NewReader("int main(){}"), 12); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Get("problems/1-input.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "1 2\n" {
+		t.Fatalf("get %q, want %q", out, "1 2\n")
+	}
+	info, err := s.Stat("codes/1.c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 12 {
+		t.Fatalf("stat size %d, want 12", info.Size)
+	}
+	infos, err := s.List("problems/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Key != "problems/1-input.txt" {
+		t.Fatalf("list problems/ get %v", infos)
+	}
+	if err := s.Delete("codes/1.c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("codes/1.c"); err != nil {
+		t.Fatalf("delete missing key: %v", err)
+	}
+	if _, err := s.Get("codes/1.c"); err != ErrNotExist {
+		t.Fatalf("get deleted key: %v", err)
+	}
+	if _, err := s.Stat("codes/1.c"); err != ErrNotExist {
+		t.Fatalf("stat deleted key: %v", err)
+	}
+}
+
+// testMeta checks that metadata is kept with objects.
+func testMeta(t *testing.T, s MetaStorage) {
+	meta := Meta{"encoding": "gzip", "size": "4"}
+	if err := s.PutMeta("problems/2-input.txt", strings.NewReader("1 2"), 3, meta); err != nil {
+		t.Fatal(err)
+	}
+	info, err := s.Stat("problems/2-input.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.Meta, meta) {
+		t.Fatalf("stat meta %v, want %v", info.Meta, meta)
+	}
+	r, info, err := s.GetMeta("problems/2-input.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if !reflect.DeepEqual(info.Meta, meta) {
+		t.Fatalf("get meta %v, want %v", info.Meta, meta)
+	}
+	// putting without metadata drops the old one
+	if err := s.Put("problems/2-input.txt", strings.NewReader("1 2\n"), 4); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := s.Stat("problems/2-input.txt"); err != nil || len(info.Meta) != 0 {
+		t.Fatalf("stat meta %v after put without meta: %v", info.Meta, err)
+	}
+	if err := s.Delete("problems/2-input.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStorage(t, NewMemory())
+	testMeta(t, NewMemory())
+}
+
+func TestLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+	testMeta(t, s)
+	// files keeping metadata are not listed
+	if err := s.PutMeta("codes/2.c", strings.NewReader("int"), 3, Meta{"size": "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if infos, err := s.List("codes/"); err != nil || len(infos) != 1 {
+		t.Fatalf("list codes/ get %v: %v", infos, err)
+	}
+}
+
+func TestPutIfAbsent(t *testing.T) {
+	s := NewMemory()
+	digest := Digest([]byte("3\n"))
+	key := "problems/sha256/" + digest
+	if err := PutIfAbsent(s, key, strings.NewReader("3\n"), 2, digest); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := s.Stat(key)
+	if err := PutIfAbsent(s, key, strings.NewReader("3\n"), 2, digest); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := s.Stat(key)
+	if !after.ModTime.Equal(before.ModTime) {
+		t.Fatal("duplicate content is written again")
+	}
+
+	// corrupt content of the same size is replaced
+	if err := s.Put(key, strings.NewReader("4\n"), 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := PutIfAbsent(s, key, strings.NewReader("3\n"), 2, digest); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := hasDigest(s, key, digest); !ok || err != nil {
+		t.Fatalf("corrupt content is kept: %v", err)
+	}
+
+	// old content is written again to refresh it
+	defer func(refresh time.Duration) { BlobRefresh = refresh }(BlobRefresh)
+	BlobRefresh = 0
+	before, _ = s.Stat(key)
+	if err := PutIfAbsent(s, key, strings.NewReader("3\n"), 2, digest); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ = s.Stat(key); !after.ModTime.After(before.ModTime) {
+		t.Fatal("old content is not refreshed")
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	digest := Digest([]byte("1 2\n"))
+	if _, err := ioutil.ReadAll(NewVerifier(strings.NewReader("1 2\n"), digest)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(NewVerifier(strings.NewReader("1 "), digest)); err != ErrCorrupt {
+		t.Fatalf("read half file get %v, want %v", err, ErrCorrupt)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	primary, secondary := NewMemory(), NewMemory()
+	testStorage(t, NewMirror(primary, secondary))
+	m := NewMirror(primary, secondary)
+	if err := m.Put("codes/2.c", strings.NewReader("int main(){}"), 12); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := secondary.Stat("codes/2.c"); err != nil {
+		t.Fatalf("secondary is not written: %v", err)
+	}
+	secondary.Put("codes/3.c", strings.NewReader("int"), 3)
+	if _, err := m.Stat("codes/3.c"); err != nil {
+		t.Fatalf("missing key is not read from secondary: %v", err)
+	}
+}
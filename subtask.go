@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// subtasksOf returns the subtasks of a problem in order.
+func subtasksOf(problemId int64) ([]model.Subtask, error) {
+	var subtasks []model.Subtask
+	err := engine.Where("problem_id = ?", problemId).Asc("nth").Find(&subtasks)
+	return subtasks, err
+}
+
+// checkSubtasks checks subtasks of a problem with n test cases.
+func checkSubtasks(subtasks []model.Subtask, n int) error {
+	for i := range subtasks {
+		if err := subtasks[i].Check(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceSubtasks replaces the subtasks of problem with subtasks in session s.
+func replaceSubtasks(s *xorm.Session, problem model.Problem, subtasks []model.Subtask) error {
+	if _, err := s.Where("problem_id = ?", problem.Id).Delete(&model.Subtask{}); err != nil {
+		return err
+	}
+	for i := range subtasks {
+		subtasks[i].Id = 0
+		subtasks[i].ProblemId = problem.Id
+		subtasks[i].Nth = i + 1
+		if _, err := s.InsertOne(&subtasks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putSubtasks replaces the subtasks of a problem, the test cases must be
+// uploaded before.
+func putSubtasks(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var req struct {
+		Subtasks []model.Subtask `json:"subtasks"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cases, err := casesOf(problem.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkSubtasks(req.Subtasks, len(cases)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = revise(problem.Id, func(s *xorm.Session) error {
+		return replaceSubtasks(s, problem, req.Subtasks)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subtasks": req.Subtasks})
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+	"github.com/satori/go.uuid"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+	"github.com/ggaaooppeenngg/validator"
+)
+
+// dataQuota is the limit of the total size of tests of a problem in byte.
+var dataQuota int64 = 256 << 20
+
+// casesOf returns the test cases of a problem in order.
+func casesOf(problemId int64) ([]model.TestCase, error) {
+	var cases []model.TestCase
+	err := engine.Where("problem_id = ?", problemId).Asc("nth").Find(&cases)
+	return cases, err
+}
+
+// dataSize returns the total size of tests of problem.
+func dataSize(problem model.Problem) (int64, error) {
+	cases, err := casesOf(problem.Id)
+	if err != nil {
+		return 0, err
+	}
+	size := problem.InputSize + problem.OutputSize
+	for _, t := range cases {
+		size += t.InputSize + t.OutputSize
+	}
+	return size, nil
+}
+
+// saveCases saves inputs and outputs of test cases as content addressed
+// files, they are not yet referred to by any test case.
+func saveCases(inputs, outputs []string) ([]model.TestCase, error) {
+	if len(inputs) != len(outputs) {
+		return nil, fmt.Errorf("%d inputs but %d outputs", len(inputs), len(outputs))
+	}
+	var cases []model.TestCase
+	for i := range inputs {
+		var t model.TestCase
+		for name, content := range map[string]string{"input": inputs[i], "output": outputs[i]} {
+			digest, err := SaveBlob("problems", content)
+			if err != nil {
+				return nil, err
+			}
+			t.Set(name, digest, int64(len(content)))
+		}
+		cases = append(cases, t)
+	}
+	return cases, nil
+}
+
+// replaceCases replaces the tests of problem with cases in session s.
+func replaceCases(s *xorm.Session, problem model.Problem, cases []model.TestCase) error {
+	if _, err := s.Where("problem_id = ?", problem.Id).Delete(&model.TestCase{}); err != nil {
+		return err
+	}
+	for i := range cases {
+		cases[i].Id = 0
+		cases[i].ProblemId = problem.Id
+		cases[i].Nth = i + 1
+		if _, err := s.InsertOne(&cases[i]); err != nil {
+			return err
+		}
+	}
+	// tests of problems created before test cases are replaced too
+	_, err := s.Id(problem.Id).Cols("input_hash", "input_size", "output_hash", "output_size").Update(&model.Problem{})
+	return err
+}
+
+// subtasksError is a change of test cases the subtasks of the problem do
+// not fit.
+type subtasksError struct {
+	err error
+}
+
+func (e *subtasksError) Error() string {
+	return "subtasks do not fit the tests, change them first: " + e.err.Error()
+}
+
+// replaceCasesTx is replaceCases in a transaction of its own, which makes a
+// revision of problem. The subtasks of problem must fit the new cases.
+func replaceCasesTx(problem model.Problem, cases []model.TestCase) error {
+	return revise(problem.Id, func(s *xorm.Session) error {
+		var subtasks []model.Subtask
+		if err := s.Where("problem_id = ?", problem.Id).Find(&subtasks); err != nil {
+			return err
+		}
+		if err := checkSubtasks(subtasks, len(cases)); err != nil {
+			return &subtasksError{err}
+		}
+		return replaceCases(s, problem, cases)
+	})
+}
+
+// replaceStatus returns the status code of an error of replaceCasesTx.
+func replaceStatus(err error) int {
+	if _, ok := err.(*subtasksError); ok {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func getProblem(c *gin.Context, id interface{}) (model.Problem, bool) {
+	var problem model.Problem
+	has, err := engine.Id(id).Get(&problem)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return problem, false
+	}
+	if !has {
+		c.JSON(http.StatusNotFound, gin.H{"error": "problem not found"})
+		return problem, false
+	}
+	return problem, true
+}
+
+// putTests replaces the test cases of a problem with the "input" and
+// "output" parts of a multipart body streamed to the storage, the nth input
+// part and the nth output part make the nth test case. Without "output"
+// parts the outputs are answered by the main solution.
+func putTests(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var (
+		inputs, outputs []model.TestCase
+		total           int64
+	)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		name := part.FormName()
+		if name != "input" && name != "output" {
+			part.Close()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown test %s", name)})
+			return
+		}
+		digest, size, err := SaveBlobFrom("problems", part, dataQuota-total)
+		part.Close()
+		if err == ErrQuotaExceeded {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		total += size
+		var t model.TestCase
+		t.Set(name, digest, size)
+		if name == "input" {
+			inputs = append(inputs, t)
+		} else {
+			outputs = append(outputs, t)
+		}
+	}
+	if len(inputs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no tests"})
+		return
+	}
+	// inputs without outputs are answered by the main solution
+	answered := len(outputs) == 0
+	if !answered && len(inputs) != len(outputs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d inputs but %d outputs", len(inputs), len(outputs))})
+		return
+	}
+	cases := inputs
+	for i := range outputs {
+		cases[i].Set("output", outputs[i].OutputHash, outputs[i].OutputSize)
+	}
+	if !validTests(c, problem, cases) {
+		return
+	}
+	if answered && !answerTests(c, problem, cases, dataQuota-total) {
+		return
+	}
+	if err := replaceCasesTx(problem, cases); err != nil {
+		c.JSON(replaceStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cases": cases})
+}
+
+// createUpload starts a resumable upload of a test.
+func createUpload(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var upload model.Upload
+	if err := c.BindJSON(&upload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errs := validator.Validate(upload); errs != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errs})
+		return
+	}
+	if upload.Name != "input" && upload.Name != "output" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown test %s", upload.Name)})
+		return
+	}
+	t, err := getCase(problem.Id, upload.Nth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if t.Id == 0 {
+		session := engine.NewSession()
+		next, err := nextNth(session, problem.Id)
+		session.Close()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if upload.Nth != next {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("test %d is neither a test case nor the next one, %d", upload.Nth, next)})
+			return
+		}
+	}
+	left, err := quotaLeft(problem, t, upload.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if upload.Size > left {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": ErrQuotaExceeded.Error()})
+		return
+	}
+	upload.Id = uuid.NewV4().String()
+	upload.ProblemId = problem.Id
+	upload.Received = 0
+	upload.Hash = ""
+	if _, err := engine.InsertOne(&upload); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"upload": upload})
+}
+
+func getUpload(c *gin.Context) (model.Upload, bool) {
+	var upload model.Upload
+	has, err := engine.Id(c.Param("id")).Get(&upload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return upload, false
+	}
+	if !has {
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+		return upload, false
+	}
+	return upload, true
+}
+
+// parseContentRange parses "bytes start-end/total".
+func parseContentRange(s string) (start, end, total int64, err error) {
+	if _, err = fmt.Sscanf(s, "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		return 0, 0, 0, fmt.Errorf("bad Content-Range %q", s)
+	}
+	if start < 0 || end < start || total <= end {
+		return 0, 0, 0, fmt.Errorf("bad Content-Range %q", s)
+	}
+	return start, end, total, nil
+}
+
+// putChunk receives the chunk in Content-Range, the test is saved once
+// all chunks are received.
+func putChunk(c *gin.Context) {
+	upload, ok := getUpload(c)
+	if !ok {
+		return
+	}
+	start, end, total, err := parseContentRange(c.Request.Header.Get("Content-Range"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if total != upload.Size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("total size %d, want %d", total, upload.Size)})
+		return
+	}
+	if start != upload.Received {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("chunk starts at %d, want %d", start, upload.Received), "upload": upload})
+		return
+	}
+	size := end - start + 1
+	if c.Request.ContentLength != size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Length does not match Content-Range"})
+		return
+	}
+	if err := store.Put(upload.ChunkPath(start), c.Request.Body, size); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	affected, err := engine.Where("id = ? AND received = ?", upload.Id, start).Cols("received").Update(&model.Upload{Received: end + 1})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if affected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "chunk uploaded concurrently"})
+		return
+	}
+	upload.Received = end + 1
+	if upload.Received < upload.Size {
+		c.JSON(http.StatusOK, gin.H{"upload": upload})
+		return
+	}
+
+	problem, ok := getProblem(c, upload.ProblemId)
+	if !ok {
+		return
+	}
+	t, err := getCase(problem.Id, upload.Nth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	left, err := quotaLeft(problem, t, upload.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	chunks, err := store.List(upload.ChunkPrefix())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	digest, size, err := SaveBlobFrom("problems", &chunkReader{chunks: chunks}, left)
+	if err == ErrQuotaExceeded {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	t.Set(upload.Name, digest, size)
+	if upload.Name == "input" && !validTests(c, problem, []model.TestCase{t}) {
+		// the input is uploaded again by a new upload
+		deleteUpload(upload, chunks)
+		return
+	}
+	// a new test case waits for its other file
+	var other model.Upload
+	if t.Id == 0 {
+		otherName := "output"
+		if upload.Name == "output" {
+			otherName = "input"
+		}
+		has, err := engine.Where("problem_id = ? AND nth = ? AND name = ? AND hash <> ''", problem.Id, upload.Nth, otherName).Desc("created_at").Get(&other)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !has {
+			if _, err := engine.Id(upload.Id).Cols("hash").Update(&model.Upload{Hash: digest}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			for _, chunk := range chunks {
+				store.Delete(chunk.Key)
+			}
+			upload.Hash = digest
+			c.JSON(http.StatusOK, gin.H{"upload": upload})
+			return
+		}
+		t.Set(other.Name, other.Hash, other.Size)
+	}
+	err = revise(problem.Id, func(s *xorm.Session) error {
+		if t.Id != 0 {
+			_, err := s.Id(t.Id).Cols("input_hash", "input_size", "output_hash", "output_size").Update(&t)
+			return err
+		}
+		// test cases may be added since the upload started
+		next, err := nextNth(s, problem.Id)
+		if err != nil {
+			return err
+		}
+		if t.Nth != next {
+			return errNthTaken
+		}
+		_, err = s.InsertOne(&t)
+		return err
+	})
+	if err == errNthTaken {
+		deleteUpload(upload, chunks)
+		deleteUpload(other, nil)
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	deleteUpload(upload, chunks)
+	deleteUpload(other, nil)
+	c.JSON(http.StatusOK, gin.H{"upload": upload, "case": t})
+}
+
+var errNthTaken = errors.New("test cases were added meanwhile, upload the test again")
+
+// deleteUpload deletes upload and its chunks.
+func deleteUpload(upload model.Upload, chunks []storage.Info) {
+	for _, chunk := range chunks {
+		store.Delete(chunk.Key)
+	}
+	if upload.Id != "" {
+		engine.Id(upload.Id).Delete(&model.Upload{})
+	}
+}
+
+// nextNth returns the nth of a new test case of problem id in session s.
+func nextNth(s *xorm.Session, problemId int64) (int, error) {
+	n, err := s.Where("problem_id = ?", problemId).Count(&model.TestCase{})
+	return int(n) + 1, err
+}
+
+// getCase returns the nth test case of a problem, a new test case is
+// returned if there is none.
+func getCase(problemId int64, nth int) (model.TestCase, error) {
+	t := model.TestCase{ProblemId: problemId, Nth: nth}
+	_, err := engine.Where("problem_id = ? AND nth = ?", problemId, nth).Get(&t)
+	return t, err
+}
+
+// quotaLeft returns how many bytes the file name of test case t can take.
+func quotaLeft(problem model.Problem, t model.TestCase, name string) (int64, error) {
+	size, err := dataSize(problem)
+	if err != nil {
+		return 0, err
+	}
+	if name == "input" {
+		size -= t.InputSize
+	} else {
+		size -= t.OutputSize
+	}
+	return dataQuota - size, nil
+}
+
+// chunkReader reads chunks one after another, opening each only when it
+// is reached.
+type chunkReader struct {
+	chunks []storage.Info
+	cur    io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			var err error
+			if r.cur, err = store.Get(r.chunks[0].Key); err != nil {
+				return 0, err
+			}
+			r.chunks = r.chunks[1:]
+		}
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			r.cur.Close()
+			r.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
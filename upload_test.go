@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+func TestParseContentRange(t *testing.T) {
+	start, end, total, err := parseContentRange("bytes 0-9/20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != 0 || end != 9 || total != 20 {
+		t.Fatalf("get %d-%d/%d", start, end, total)
+	}
+	for _, s := range []string{"", "bytes 9-0/20", "bytes 0-20/20", "bytes */20"} {
+		if _, _, _, err := parseContentRange(s); err == nil {
+			t.Fatalf("parse %q get no error", s)
+		}
+	}
+}
+
+func TestChunkReader(t *testing.T) {
+	store = storage.NewMemory()
+	upload := model.Upload{Id: "test"}
+	// offsets are zero padded so chunk 12 is listed after chunk 2
+	for offset, chunk := range map[int64]string{0: "1\n", 2: "0123456789", 12: "1 2\n"} {
+		if err := store.Put(upload.ChunkPath(offset), strings.NewReader(chunk), int64(len(chunk))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chunks, err := store.List(upload.ChunkPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(&chunkReader{chunks: chunks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "1\n01234567891 2\n" {
+		t.Fatalf("get %q", out)
+	}
+}
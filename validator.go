@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ggaaooppeenngg/OJ/model"
+)
+
+// caseError is why a test case is invalid.
+type caseError struct {
+	Nth     int    `json:"nth"`
+	Message string `json:"message"`
+}
+
+// validateCases runs the validator of problem on the input of every test
+// case like a testlib validator reading stdin, and returns the test cases
+// it rejects. Every test case is valid without a validator.
+func validateCases(problem model.Problem, cases []model.TestCase) ([]caseError, error) {
+	if problem.ValidatorHash == "" {
+		return nil, nil
+	}
+	binary, err := compileJury(problem.ValidatorPath(), problem.ValidatorHash)
+	if err != nil {
+		return nil, err
+	}
+	var invalid []caseError
+	for i, t := range cases {
+		nth := t.Nth
+		if nth == 0 {
+			nth = i + 1
+		}
+		r, err := GetFile(t.InputPath())
+		if err != nil {
+			return nil, err
+		}
+		err = runJury(binary, nil, r, ioutil.Discard)
+		r.Close()
+		if e, ok := err.(*juryError); ok {
+			invalid = append(invalid, caseError{Nth: nth, Message: e.Error()})
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return invalid, nil
+}
+
+// validTests validates the inputs of cases of problem, a response with the
+// invalid test cases is written if any is invalid.
+func validTests(c *gin.Context, problem model.Problem, cases []model.TestCase) bool {
+	invalid, err := validateCases(problem, cases)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if len(invalid) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tests", "invalid": invalid})
+		return false
+	}
+	return true
+}
+
+// validate validates the test cases of a problem, after its validator is
+// changed for example.
+func validate(c *gin.Context) {
+	problem, ok := getProblem(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	cases, err := casesOf(problem.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	invalid, err := validateCases(problem, cases)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if invalid == nil {
+		invalid = []caseError{}
+	}
+	c.JSON(http.StatusOK, gin.H{"invalid": invalid})
+}
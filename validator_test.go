@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ggaaooppeenngg/OJ/confine"
+	"github.com/ggaaooppeenngg/OJ/model"
+	"github.com/ggaaooppeenngg/OJ/storage"
+)
+
+func TestValidateCases(t *testing.T) {
+	store = storage.NewMemory()
+	dir, err := confine.Contestant.TempDir("jury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	juryDir = dir
+	// a compiled validator is found by the digest of its source
+	problem := model.Problem{ValidatorHash: "validator"}
+	script := "#!/bin/sh\nread a b rest\nif [ -n \"$rest\" ] || [ \"$a\" -gt 9 ]; then echo \"bad line '$a $b $rest'\" >&2; exit 3; fi\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "validator"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var cases []model.TestCase
+	for _, input := range []string{"1 2\n", "1 2 3\n", "10 2\n"} {
+		digest, err := SaveBlob("problems", input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, model.TestCase{InputHash: digest})
+	}
+	invalid, err := validateCases(problem, cases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []caseError{{2, "exit 3: bad line '1 2 3'"}, {3, "exit 3: bad line '10 2 '"}}
+	if !reflect.DeepEqual(invalid, want) {
+		t.Errorf("get invalid cases %v, want %v", invalid, want)
+	}
+	if invalid, err := validateCases(model.Problem{}, cases); err != nil || invalid != nil {
+		t.Errorf("validate without validator: %v, %v", invalid, err)
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	problem := model.Problem{
+		Generators: []model.Generator{{Name: "gen"}, {Name: "tree"}},
+		Script:     "# small\ngen 1 10\n\n  tree -n 5 \n",
+	}
+	if err := problem.CheckGenerators(); err != nil {
+		t.Fatal(err)
+	}
+	lines, err := problem.ParseScript()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.ScriptLine{{Generator: "gen", Args: []string{"1", "10"}}, {Generator: "tree", Args: []string{"-n", "5"}}}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("parse script to %v, want %v", lines, want)
+	}
+	problem.Script += "graph 3\n"
+	if err := problem.CheckGenerators(); err == nil {
+		t.Error("check a script with an unknown generator without error")
+	}
+	problem.Generators = append(problem.Generators, model.Generator{Name: "gen"})
+	if err := problem.CheckGenerators(); err == nil {
+		t.Error("check duplicate generators without error")
+	}
+}